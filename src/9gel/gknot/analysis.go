@@ -0,0 +1,305 @@
+// Analysis of how confusing a puzzle is: which of its states lead towards
+// taking it apart and which lead astray.
+package gknot
+
+import (
+	"fmt"
+	"sort"
+)
+
+// How a state of a StateGraph relates to the puzzle's solution.
+type StateClass int
+
+const (
+	// On a shortest path from the start to a separated state.
+	OnSolutionPath = StateClass(0)
+	// In a branch off the rest of the states that only leads to dead ends, so
+	// that the only way on is back the way in.
+	DeadEndBranch = StateClass(1)
+	// Off the shortest solution paths, and on a loop that can be moved around
+	// without going back the same way, or only leading on to such loops.
+	CycleRegion = StateClass(2)
+	// A separated state that takes more moves to reach than the fewest.
+	LongerSolution = StateClass(3)
+	// Off the shortest solution paths and on no loop, but leading on to a
+	// longer solution.
+	TowardsLongerSolution = StateClass(4)
+)
+
+var stateClassNames = []string{"on a shortest solution path", "in a dead-end branch", "in loops off the solution path",
+	"separated by more than the fewest moves", "on the way to a longer solution"}
+
+func (class StateClass) String() string {
+	if int(class) < len(stateClassNames) {
+		return stateClassNames[class]
+	}
+	return fmt.Sprintf("StateClass(%d)", int(class))
+}
+
+// A branch of states leading only to dead ends.
+type Branch struct {
+	// The indices in StateGraph.Nodes of the state the branch leaves from,
+	// which is not in the branch, and of the first state in the branch.
+	From  int
+	Entry int
+	// The move from From to Entry.
+	Move Move
+	// The number of states in the branch.
+	Size int
+	// The most moves from From into the branch.
+	Depth int
+}
+
+// The classes of the states of a StateGraph, and the branches that lead to
+// dead ends.
+type Analysis struct {
+	Graph *StateGraph
+	// The class of each state, by index in Graph.Nodes.
+	Classes []StateClass
+	// The fewest moves from each state to a separated state, or -1 if there
+	// is no way to one.
+	MovesToSolution []int
+	// Deepest first.
+	Branches []Branch
+	// The moves from the starting state and the class of the state each leads
+	// to. The moves that do not lead onto a shortest solution path are traps.
+	StartMoves   []Move
+	StartClasses []StateClass
+}
+
+// Classifies the states of the graph. Only the states in the graph are
+// considered, so that the analysis of a graph cut short by a limit is only
+// approximate.
+func (graph *StateGraph) Analyze() *Analysis {
+	numNodes := len(graph.Nodes)
+	analysis := &Analysis{
+		Graph:           graph,
+		Classes:         make([]StateClass, numNodes),
+		MovesToSolution: make([]int, numNodes),
+	}
+	neighbours := make([][]int, numNodes)
+	// The indices in graph.Edges of the edges of each state.
+	edges := make([][]int, numNodes)
+	moves := make([]map[int]Move, numNodes)
+	for i := range moves {
+		moves[i] = make(map[int]Move)
+	}
+	for e, edge := range graph.Edges {
+		neighbours[edge.From] = append(neighbours[edge.From], edge.To)
+		neighbours[edge.To] = append(neighbours[edge.To], edge.From)
+		edges[edge.From] = append(edges[edge.From], e)
+		edges[edge.To] = append(edges[edge.To], e)
+		moves[edge.From][edge.To] = edge.Move
+		moves[edge.To][edge.From] = edge.Move.inverse()
+	}
+
+	// Breadth first from all the separated states at once.
+	var queue []int
+	for i, node := range graph.Nodes {
+		analysis.MovesToSolution[i] = -1
+		if node.Separated {
+			analysis.MovesToSolution[i] = 0
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		for _, j := range neighbours[i] {
+			if analysis.MovesToSolution[j] < 0 {
+				analysis.MovesToSolution[j] = analysis.MovesToSolution[i] + 1
+				queue = append(queue, j)
+			}
+		}
+	}
+
+	// Peel off the states that lead nowhere else, keeping the start and the
+	// separated states, until only paths between those and loops are left.
+	degrees := make([]int, numNodes)
+	peeled := make([]bool, numNodes)
+	for i := range graph.Nodes {
+		degrees[i] = len(neighbours[i])
+		if degrees[i] <= 1 && i != 0 && !graph.Nodes[i].Separated {
+			queue = append(queue, i)
+		}
+	}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		peeled[i] = true
+		for _, j := range neighbours[i] {
+			if degrees[j]--; degrees[j] == 1 && !peeled[j] && j != 0 && !graph.Nodes[j].Separated {
+				queue = append(queue, j)
+			}
+		}
+	}
+
+	onCycle, ahead := graph.findCycles(edges, peeled)
+	fewestMoves := analysis.MovesToSolution[0]
+	for i, node := range graph.Nodes {
+		switch {
+		case fewestMoves >= 0 && node.Depth+analysis.MovesToSolution[i] == fewestMoves:
+			analysis.Classes[i] = OnSolutionPath
+		case peeled[i]:
+			analysis.Classes[i] = DeadEndBranch
+		case node.Separated:
+			analysis.Classes[i] = LongerSolution
+		case onCycle[i]:
+			analysis.Classes[i] = CycleRegion
+		case ahead[i].separated:
+			analysis.Classes[i] = TowardsLongerSolution
+		case ahead[i].cycle:
+			analysis.Classes[i] = CycleRegion
+		default:
+			analysis.Classes[i] = DeadEndBranch
+		}
+	}
+
+	// Each branch is a tree of peeled states hanging off a state that is not.
+	for i := range graph.Nodes {
+		if peeled[i] {
+			continue
+		}
+		for _, entry := range neighbours[i] {
+			if !peeled[entry] {
+				continue
+			}
+			branch := Branch{From: i, Entry: entry, Move: moves[i][entry]}
+			depths := map[int]int{i: 0, entry: 1}
+			for queue = []int{entry}; len(queue) > 0; queue = queue[1:] {
+				j := queue[0]
+				branch.Size++
+				if depths[j] > branch.Depth {
+					branch.Depth = depths[j]
+				}
+				for _, k := range neighbours[j] {
+					if _, ok := depths[k]; !ok && peeled[k] {
+						depths[k] = depths[j] + 1
+						queue = append(queue, k)
+					}
+				}
+			}
+			analysis.Branches = append(analysis.Branches, branch)
+		}
+	}
+	sort.SliceStable(analysis.Branches, func(i, j int) bool {
+		return analysis.Branches[i].Depth > analysis.Branches[j].Depth
+	})
+
+	for _, edge := range graph.Edges {
+		// The start is expanded first, so its moves are all listed from it.
+		if edge.From == 0 {
+			analysis.StartMoves = append(analysis.StartMoves, edge.Move)
+			analysis.StartClasses = append(analysis.StartClasses, analysis.Classes[edge.To])
+		}
+	}
+	return analysis
+}
+
+// What the states beyond a state lead to, going away from the start.
+type statesAhead struct {
+	separated bool
+	cycle     bool
+}
+
+// Finds the states left after peeling that lie on a cycle, that is, have an
+// edge that is not a bridge, depth first from the start. For the states on
+// no cycle, whose edges are all bridges, also finds what the states beyond
+// them lead to: their descendants in the search, which are cut off from the
+// start by them.
+func (graph *StateGraph) findCycles(edges [][]int, peeled []bool) (onCycle []bool, ahead []statesAhead) {
+	numNodes := len(graph.Nodes)
+	onCycle = make([]bool, numNodes)
+	ahead = make([]statesAhead, numNodes)
+	// The order each state is visited in, from 1, and the lowest order
+	// reached from its descendants by one edge not in the search tree.
+	order, low := make([]int, numNodes), make([]int, numNodes)
+	visited := 0
+	var visit func(i, parentEdge int)
+	visit = func(i, parentEdge int) {
+		visited++
+		order[i], low[i] = visited, visited
+		for _, e := range edges[i] {
+			edge := graph.Edges[e]
+			j := edge.From
+			if j == i {
+				j = edge.To
+			}
+			if peeled[j] || e == parentEdge {
+				continue
+			}
+			if order[j] > 0 {
+				// An edge back to a state already visited closes a cycle.
+				onCycle[i], onCycle[j] = true, true
+				ahead[i].cycle = true
+				if order[j] < low[i] {
+					low[i] = order[j]
+				}
+				continue
+			}
+			visit(j, e)
+			if low[j] < low[i] {
+				low[i] = low[j]
+			}
+			if low[j] <= order[i] {
+				onCycle[i], onCycle[j] = true, true
+			}
+			ahead[i].separated = ahead[i].separated || ahead[j].separated || graph.Nodes[j].Separated
+			ahead[i].cycle = ahead[i].cycle || ahead[j].cycle
+		}
+	}
+	if numNodes > 0 {
+		visit(0, -1)
+	}
+	return onCycle, ahead
+}
+
+// The move that undoes this one.
+func (move Move) inverse() Move {
+	inverse := move
+	inverse.From, inverse.To = move.To, move.From
+	for i := range inverse.Translation {
+		inverse.Translation[i] = -inverse.Translation[i]
+	}
+	inverse.Rotation.Turn = -inverse.Rotation.Turn
+	return inverse
+}
+
+// Prints a report of the analysis: how many states are in each class, the
+// dead-end branches and where the moves from the start lead.
+func (analysis *Analysis) Print() {
+	graph := analysis.Graph
+	counts := make([]int, len(stateClassNames))
+	for _, class := range analysis.Classes {
+		counts[class]++
+	}
+	fmt.Printf("%v states, stopped: %v.\n", len(graph.Nodes), graph.Reason)
+	if fewestMoves := analysis.MovesToSolution[0]; fewestMoves >= 0 {
+		fmt.Printf("Fewest moves until a group is free: %v.\n", fewestMoves)
+	} else {
+		fmt.Println("No group can be freed.")
+	}
+	for class, count := range counts {
+		fmt.Printf("%v states %v.\n", count, StateClass(class))
+	}
+
+	fmt.Printf("%v dead-end branches", len(analysis.Branches))
+	if len(analysis.Branches) > 0 {
+		fmt.Println(", deepest first:")
+	} else {
+		fmt.Println(".")
+	}
+	for _, branch := range analysis.Branches {
+		fmt.Printf("  From %v %v: %v moves deep, %v states.\n",
+			graph.Nodes[branch.From].ID, graph.Describe(branch.Move), branch.Depth, branch.Size)
+	}
+
+	fmt.Println("Moves from the start:")
+	for i, move := range analysis.StartMoves {
+		fmt.Printf("  %v: %v", graph.Describe(move), analysis.StartClasses[i])
+		if analysis.StartClasses[i] != OnSolutionPath {
+			fmt.Print(" (trap)")
+		}
+		fmt.Println()
+	}
+}
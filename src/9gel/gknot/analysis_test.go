@@ -0,0 +1,125 @@
+package gknot
+
+import (
+	"reflect"
+	"testing"
+)
+
+// A graph with a shortest solution through state 1, a dead-end branch off it,
+// a loop back to the start and a longer solution.
+//
+//	    3 - 4
+//	    |
+//	0 - 1 - 2 (separated)
+//	| \
+//	|  5 - 6 - 0
+//	7 - 8 - 9 (separated)
+func analysisGraph() *StateGraph {
+	depths := []int{0, 1, 2, 2, 3, 1, 1, 1, 2, 3}
+	graph := &StateGraph{pieceNames: map[uint8]string{31: "Red", 32: "Green"}}
+	for i, depth := range depths {
+		graph.Nodes = append(graph.Nodes, StateNode{ID: StateID(rune('A' + i)), Depth: depth})
+	}
+	graph.Nodes[2].Separated = true
+	graph.Nodes[9].Separated = true
+	red, green := []uint8{31}, []uint8{32}
+	graph.Edges = []StateEdge{
+		{0, 1, Move{PieceIDs: red, Translation: Translation{1, 0, 0}}},
+		{0, 5, Move{PieceIDs: green, Translation: Translation{0, 1, 0}}},
+		{0, 6, Move{PieceIDs: green, Translation: Translation{0, -1, 0}}},
+		{0, 7, Move{PieceIDs: red, Translation: Translation{0, 0, 2}}},
+		{1, 2, Move{PieceIDs: red, Translation: Translation{1, 0, 0}}},
+		{1, 3, Move{PieceIDs: green, Translation: Translation{0, 0, -1}}},
+		{5, 6, Move{PieceIDs: green, Translation: Translation{0, -2, 0}}},
+		{7, 8, Move{PieceIDs: green, Translation: Translation{1, 0, 0}}},
+		{3, 4, Move{PieceIDs: green, Translation: Translation{0, 0, -1}}},
+		{8, 9, Move{PieceIDs: green, Translation: Translation{1, 0, 0}}},
+	}
+	return graph
+}
+
+func TestAnalyze(t *testing.T) {
+	analysis := analysisGraph().Analyze()
+	expectedClasses := []StateClass{OnSolutionPath, OnSolutionPath, OnSolutionPath, DeadEndBranch, DeadEndBranch,
+		CycleRegion, CycleRegion, TowardsLongerSolution, TowardsLongerSolution, LongerSolution}
+	if !reflect.DeepEqual(analysis.Classes, expectedClasses) {
+		t.Fatalf("Expected classes %v, actual %v.", expectedClasses, analysis.Classes)
+	}
+	expectedMoves := []int{2, 1, 0, 2, 3, 3, 3, 2, 1, 0}
+	if !reflect.DeepEqual(analysis.MovesToSolution, expectedMoves) {
+		t.Fatalf("Expected moves to solution %v, actual %v.", expectedMoves, analysis.MovesToSolution)
+	}
+	if len(analysis.Branches) != 1 {
+		t.Fatalf("Expected 1 dead-end branch, actual %v.", analysis.Branches)
+	}
+	if branch := analysis.Branches[0]; branch.From != 1 || branch.Entry != 3 || branch.Size != 2 || branch.Depth != 2 {
+		t.Fatalf("Expected a branch of 2 states 2 moves deep from state 1, actual %v.", branch)
+	}
+	expectedStartClasses := []StateClass{OnSolutionPath, CycleRegion, CycleRegion, TowardsLongerSolution}
+	if !reflect.DeepEqual(analysis.StartClasses, expectedStartClasses) {
+		t.Fatalf("Expected the start's moves to lead %v, actual %v.", expectedStartClasses, analysis.StartClasses)
+	}
+}
+
+// States on no loop that only lead into loops are in the loops' region.
+//
+//	0 - 1 (separated)
+//	|
+//	2 - 3 - 4
+//	     \ /
+//	      5
+func TestAnalyze_pathIntoLoop(t *testing.T) {
+	graph := &StateGraph{}
+	for _, depth := range []int{0, 1, 1, 2, 3, 3} {
+		graph.Nodes = append(graph.Nodes, StateNode{Depth: depth})
+	}
+	graph.Nodes[1].Separated = true
+	graph.Edges = []StateEdge{{0, 1, Move{}}, {0, 2, Move{}}, {2, 3, Move{}}, {3, 4, Move{}}, {3, 5, Move{}},
+		{4, 5, Move{}}}
+	analysis := graph.Analyze()
+	expectedClasses := []StateClass{OnSolutionPath, OnSolutionPath, CycleRegion, CycleRegion, CycleRegion,
+		CycleRegion}
+	if !reflect.DeepEqual(analysis.Classes, expectedClasses) {
+		t.Fatalf("Expected classes %v, actual %v.", expectedClasses, analysis.Classes)
+	}
+}
+
+func TestAnalyze_noSolution(t *testing.T) {
+	analysis := boxedCubeGraph(t).Analyze()
+	if analysis.MovesToSolution[0] != -1 {
+		t.Fatalf("No state should lead to a solution, actual %v.", analysis.MovesToSolution)
+	}
+	if analysis.Classes[1] != DeadEndBranch {
+		t.Fatalf("The state off the start should be a dead end, actual %v.", analysis.Classes[1])
+	}
+}
+
+func TestMove_inverse(t *testing.T) {
+	move := Move{From: "a", To: "b", Translation: Translation{0, -2, 0}}
+	if inverse := move.inverse(); inverse.From != "b" || inverse.To != "a" || inverse.Translation != (Translation{0, 2, 0}) {
+		t.Fatalf("Expected the move from b to a by [0 2 0], actual %v.", inverse)
+	}
+	rotation := Move{Rotation: Rotation{Z, 1, Cell{1, 1, 0}}}
+	if inverse := rotation.inverse(); inverse.Rotation != (Rotation{Z, -1, Cell{1, 1, 0}}) {
+		t.Fatalf("Expected the turn the other way about the same line, actual %v.", inverse.Rotation)
+	}
+}
+
+func ExampleAnalysis_Print() {
+	analysisGraph().Analyze().Print()
+	// Output:
+	// 10 states, stopped: solved.
+	// Fewest moves until a group is free: 2.
+	// 3 states on a shortest solution path.
+	// 2 states in a dead-end branch.
+	// 2 states in loops off the solution path.
+	// 1 states separated by more than the fewest moves.
+	// 2 states on the way to a longer solution.
+	// 1 dead-end branches, deepest first:
+	//   From B Green -z: 2 moves deep, 2 states.
+	// Moves from the start:
+	//   Red +x: on a shortest solution path
+	//   Green +y: in loops off the solution path (trap)
+	//   Green -y: in loops off the solution path (trap)
+	//   Red +z by 2: on the way to a longer solution (trap)
+}
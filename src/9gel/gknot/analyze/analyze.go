@@ -0,0 +1,44 @@
+// Reports how confusing a puzzle is: which of its states lead towards taking
+// it apart, which lead into dead ends or loops, and which moves from the start
+// are traps.
+package main
+
+import (
+	"9gel/gknot"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+var (
+	puzzleFile    = flag.String("puzzle", "", "Puzzle file to analyze. Defaults to the Gordian Knot.")
+	maxStates     = flag.Int("max_states", 0, "Maximum number of states to explore. 0 means no limit.")
+	maxDepth      = flag.Int("max_depth", 0, "Maximum number of moves from the start. 0 means no limit.")
+	rotations     = flag.Bool("rotations", false, "Also try quarter turns of pieces and groups.")
+	maxSubsetSize = flag.Int("max_subset_size", 0,
+		"Also slide groups of 2 up to this many pieces together as one move. 0 means one piece at a time.")
+)
+
+func main() {
+	flag.Parse()
+	puzzle, err := gknot.LoadPuzzleFile(*puzzleFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// Interrupting stops exploring and analyzes the states found so far.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	graph, err := puzzle.StateGraph(ctx, gknot.SolveOptions{
+		MaxStates:     *maxStates,
+		MaxDepth:      *maxDepth,
+		Rotations:     *rotations,
+		MaxSubsetSize: *maxSubsetSize})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	graph.Analyze().Print()
+}
@@ -0,0 +1,224 @@
+// Animated pictures of solutions.
+package gknot
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"io"
+	"math"
+	"sort"
+)
+
+// How to draw an animation of a solution.
+type AnimationOptions struct {
+	ImageOptions
+	// The frames drawn for each cell the pieces slide. 0 means 3.
+	FramesPerCell int
+	// How long each frame is shown, in hundredths of a second. 0 means 4. The
+	// first and last frames are shown for 2 seconds.
+	Delay int
+}
+
+var ErrInvalidAnimationOptions = errors.New("AnimationOptions.FramesPerCell and Delay must not be negative.")
+
+const (
+	defaultFramesPerCell = 3
+	defaultFrameDelay    = 4
+	holdFrameDelay       = 200
+)
+
+// A frame of an animation: where the pieces are drawn and what the caption
+// says.
+type animationFrame struct {
+	pieces  []isoPiece
+	caption string
+}
+
+// The frames of an animation of the solution's moves from the start. Slides
+// are shown a few frames per cell. Removed pieces slide clear of the rest of
+// the puzzle and stay there, where any moves made on them are shown. Rotations
+// are shown in one frame.
+func (solution Solution) animationFrames(start *Puzzle, framesPerCell int) []animationFrame {
+	current := make(map[uint8]isoPiece, len(start.Pieces))
+	for pieceID, piece := range start.Pieces {
+		current[pieceID] = isoPiece{Piece: piece}
+	}
+	scene := func() []isoPiece {
+		pieces := make([]isoPiece, 0, len(current))
+		for _, piece := range current {
+			pieces = append(pieces, piece)
+		}
+		sort.Slice(pieces, func(i, j int) bool {
+			return pieces[i].Definition.EscColor < pieces[j].Definition.EscColor
+		})
+		return pieces
+	}
+	frames := []animationFrame{{scene(), "Start"}}
+	steps := solution.Steps(start)
+	for i, step := range steps {
+		move := step.Move
+		caption := fmt.Sprintf("%v/%v %v", i+1, len(steps), start.Describe(move))
+		if move.Removed {
+			caption = fmt.Sprintf("%v/%v Remove %v", i+1, len(steps), start.Describe(move))
+		}
+		if move.Rotation.Turn != 0 {
+			for _, pieceID := range move.PieceIDs {
+				current[pieceID] = isoPiece{step.To.Pieces[pieceID], current[pieceID].offset}
+			}
+			frames = append(frames, animationFrame{scene(), caption})
+			continue
+		}
+
+		distance := move.Distance()
+		var unit [3]float64
+		for axis, v := range move.Translation {
+			unit[axis] = float64(v) / float64(distance)
+		}
+		moved := make(map[uint8]isoPiece, len(move.PieceIDs))
+		for _, pieceID := range move.PieceIDs {
+			moved[pieceID] = current[pieceID]
+		}
+		if move.Removed {
+			distance = clearDistance(moved, current, move.Translation)
+		}
+		numFrames := distance * framesPerCell
+		for frame := 1; frame <= numFrames; frame++ {
+			cells := float64(distance*frame) / float64(numFrames)
+			for pieceID, piece := range moved {
+				for axis := range piece.offset {
+					piece.offset[axis] += unit[axis] * cells
+				}
+				current[pieceID] = piece
+			}
+			frames = append(frames, animationFrame{scene(), caption})
+		}
+		if !move.Removed {
+			// Go on from where the pieces are in the next state.
+			for pieceID, piece := range moved {
+				current[pieceID] = isoPiece{step.To.Pieces[pieceID], piece.offset}
+			}
+		}
+	}
+	return frames
+}
+
+// How far the moved pieces slide along the unit translation to be a cell clear
+// of the rest of the pieces drawn, so that removed pieces are seen apart.
+func clearDistance(moved, all map[uint8]isoPiece, unit Translation) int {
+	// The furthest the rest reach along the translation, and the least far the
+	// moved pieces do.
+	rest, group := math.Inf(-1), math.Inf(1)
+	for pieceID, piece := range all {
+		for _, cell := range piece.Cells {
+			along := 0.0
+			for axis, v := range unit {
+				along += float64(v) * (float64(cell[axis]) + piece.offset[axis])
+			}
+			if _, ok := moved[pieceID]; ok {
+				group = math.Min(group, along)
+			} else {
+				rest = math.Max(rest, along)
+			}
+		}
+	}
+	if distance := int(rest-group) + 2; distance > 1 {
+		return distance
+	}
+	return 1
+}
+
+// Writes an animated GIF of the solution's moves from the start, drawn as
+// isometric pictures like Puzzle.Image with a caption naming the moved pieces.
+// The pieces slide a few frames per cell so that they are seen moving. Removed
+// pieces slide clear of the rest and stay there; rotations are shown in a
+// single frame.
+func (solution Solution) WriteGIF(w io.Writer, start *Puzzle, opts AnimationOptions) error {
+	camera, err := opts.camera()
+	if err != nil {
+		return err
+	}
+	if opts.FramesPerCell < 0 || opts.Delay < 0 {
+		return ErrInvalidAnimationOptions
+	}
+	framesPerCell, delay := opts.FramesPerCell, opts.Delay
+	if framesPerCell == 0 {
+		framesPerCell = defaultFramesPerCell
+	}
+	if delay == 0 {
+		delay = defaultFrameDelay
+	}
+	frames := solution.animationFrames(start, framesPerCell)
+
+	// Every frame shows the same part of the page, with the caption above.
+	bounds := emptyPageBounds()
+	for _, frame := range frames {
+		camera.extend(&bounds, frame.pieces)
+	}
+	width, height := camera.imageSize(bounds)
+	scale := int(camera.cellSize) / 12
+	if scale < 1 {
+		scale = 1
+	}
+	captionHeight := (glyphHeight + 4) * scale
+	for _, frame := range frames {
+		if captionWidth := textWidth(frame.caption, scale) + 4*scale; captionWidth > width {
+			width = captionWidth
+		}
+	}
+	textColor := color.RGBA{0, 0, 0, 0xff}
+	background := image.Image(image.Transparent)
+	if opts.Background != nil {
+		background = image.NewUniform(opts.Background)
+		r, g, b, _ := opts.Background.RGBA()
+		if r+g+b < 3*0x8000 {
+			textColor = color.RGBA{0xff, 0xff, 0xff, 0xff}
+		}
+	}
+
+	// The frames share a palette of the colours drawn, which is at most a few
+	// shades of each piece's colour.
+	var palette color.Palette
+	indices := make(map[color.RGBA]uint8)
+	if opts.Background == nil {
+		palette = append(palette, color.RGBA{})
+		indices[color.RGBA{}] = 0
+	}
+	anim := &gif.GIF{}
+	img := image.NewRGBA(image.Rect(0, 0, width, captionHeight+height))
+	for i, frame := range frames {
+		draw.Draw(img, img.Bounds(), background, image.Point{}, draw.Src)
+		drawText(img, 2*scale, 2*scale, frame.caption, scale, textColor)
+		camera.draw(img, camera.cellSize-bounds.minX, float64(captionHeight)+camera.cellSize-bounds.minY, frame.pieces)
+		paletted := image.NewPaletted(img.Bounds(), nil)
+		for j := range paletted.Pix {
+			c := color.RGBA{img.Pix[4*j], img.Pix[4*j+1], img.Pix[4*j+2], img.Pix[4*j+3]}
+			index, ok := indices[c]
+			if !ok {
+				if len(palette) < 256 {
+					index = uint8(len(palette))
+					palette = append(palette, c)
+				} else {
+					index = uint8(palette.Index(c))
+				}
+				indices[c] = index
+			}
+			paletted.Pix[j] = index
+		}
+		anim.Image = append(anim.Image, paletted)
+		if i == 0 || i == len(frames)-1 {
+			anim.Delay = append(anim.Delay, holdFrameDelay)
+		} else {
+			anim.Delay = append(anim.Delay, delay)
+		}
+		// Clear transparent frames, or else the one before shows through.
+		anim.Disposal = append(anim.Disposal, gif.DisposalBackground)
+	}
+	for _, paletted := range anim.Image {
+		paletted.Palette = palette
+	}
+	return gif.EncodeAll(w, anim)
+}
@@ -0,0 +1,107 @@
+package gknot
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+// Solves the cup puzzle for its peg.
+func cupSolution(t *testing.T) (*Puzzle, *Solution) {
+	start, err := LoadPuzzleFile("testdata/cup.json")
+	if err != nil {
+		t.Fatalf("LoadPuzzleFile returned error %v.", err)
+	}
+	solution, err := start.Solve(SolveOptions{Goal: FreeFirstPiece})
+	if err != nil {
+		t.Fatalf("Solve returned error %v.", err)
+	}
+	return start, solution
+}
+
+func TestAnimationFrames(t *testing.T) {
+	start, solution := cupSolution(t)
+	frames := solution.animationFrames(start, 2)
+	if frames[0].caption != "Start" || len(frames[0].pieces) != 2 {
+		t.Fatalf("First frame should show the start, actual %v.", frames[0])
+	}
+	// The peg slides straight out of the top of the cup, then a cell clear of
+	// it, 2 frames per cell.
+	last := frames[len(frames)-1]
+	if len(frames) != 7 || last.caption != "1/1 Remove Peg +y" {
+		t.Fatalf("Expected 7 frames ending with the peg removed, actual %v ending with %q.", len(frames), last.caption)
+	}
+	peg := last.pieces[0]
+	if peg.Definition.Name != "Peg" || peg.offset != [3]float64{0, 3, 0} {
+		t.Fatalf("Peg should end up moved by 3 along y, actual %v by %v.", peg.Definition.Name, peg.offset)
+	}
+	if halfway := frames[len(frames)-4].pieces[0].offset; halfway != [3]float64{0, 1.5, 0} {
+		t.Fatalf("Peg should be halfway out 3 frames before the end, actual %v.", halfway)
+	}
+}
+
+func TestWriteGIF(t *testing.T) {
+	start, solution := cupSolution(t)
+	var buf bytes.Buffer
+	opts := AnimationOptions{ImageOptions: ImageOptions{CellSize: 12, Background: color.White}, Delay: 10}
+	if err := solution.WriteGIF(&buf, start, opts); err != nil {
+		t.Fatalf("WriteGIF returned error %v.", err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("WriteGIF should write a GIF, actual error %v.", err)
+	}
+	numFrames := len(solution.animationFrames(start, defaultFramesPerCell))
+	if len(anim.Image) != numFrames {
+		t.Fatalf("Expected %v frames, actual %v.", numFrames, len(anim.Image))
+	}
+	bounds := anim.Image[0].Bounds()
+	for i, frame := range anim.Image {
+		if frame.Bounds() != bounds {
+			t.Fatalf("Frame %v should be %v like the first, actual %v.", i, bounds, frame.Bounds())
+		}
+	}
+	if anim.Delay[0] != holdFrameDelay || anim.Delay[1] != 10 || anim.Delay[numFrames-1] != holdFrameDelay {
+		t.Fatalf("Expected the first and last frames held, actual delays %v.", anim.Delay)
+	}
+	if c := anim.Image[0].At(bounds.Max.X-1, bounds.Max.Y-1); c != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Fatalf("Corner should be the background, actual %v.", c)
+	}
+}
+
+func TestWriteGIF_errors(t *testing.T) {
+	start, solution := cupSolution(t)
+	for _, opts := range []AnimationOptions{{FramesPerCell: -1}, {Delay: -1}} {
+		if err := solution.WriteGIF(&bytes.Buffer{}, start, opts); err != ErrInvalidAnimationOptions {
+			t.Fatalf("Expected ErrInvalidAnimationOptions for %v, actual %v.", opts, err)
+		}
+	}
+	opts := AnimationOptions{ImageOptions: ImageOptions{CellSize: -1}}
+	if err := solution.WriteGIF(&bytes.Buffer{}, start, opts); err != ErrInvalidImageOptions {
+		t.Fatalf("Expected ErrInvalidImageOptions, actual %v.", err)
+	}
+}
+
+func TestDrawText(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	black := color.RGBA{0, 0, 0, 0xff}
+	drawText(img, 1, 1, "i-", 2, black)
+	// The top bar of the capital I, 2 pixels per dot.
+	for x := 3; x < 9; x++ {
+		if img.RGBAAt(x, 1) != black || img.RGBAAt(x, 2) != black {
+			t.Fatalf("Expected the top of I at (%v, 1), actual %v.", x, img.RGBAAt(x, 1))
+		}
+	}
+	if img.RGBAAt(1, 1) == black {
+		t.Fatalf("Top left corner of I should not be drawn.")
+	}
+	// The dash follows 6 dots on.
+	if img.RGBAAt(13, 7) != black {
+		t.Fatalf("Expected the dash at (13, 7).")
+	}
+	if width := textWidth("i-", 2); width != 24 {
+		t.Fatalf("Expected text 24 pixels wide, actual %v.", width)
+	}
+}
@@ -0,0 +1,403 @@
+// Reading and writing BurrTools puzzle files.
+//
+// BurrTools saves puzzles as gzipped XML, in .xmpuzzle files. A file has a
+// list of shapes, each a grid of voxels, and problems that put copies of the
+// shapes together into a result shape. The pieces of a puzzle are the shapes
+// of its first problem, placed as in the problem's first assembly. Only
+// puzzles on cubic grids, and pieces that are not mirrored, are supported.
+//
+// BurrTools has no piece IDs, so the shapes are written named after the
+// pieces followed by their IDs in brackets, such as "Blue (36)", which
+// BurrTools keeps when it saves the file again.
+package gknot
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type xmPuzzle struct {
+	XMLName  xml.Name    `xml:"puzzle"`
+	Version  int         `xml:"version,attr"`
+	GridType xmGridType  `xml:"gridType"`
+	Colors   struct{}    `xml:"colors"`
+	Shapes   []xmVoxel   `xml:"shapes>voxel"`
+	Problems []xmProblem `xml:"problems>problem"`
+}
+
+type xmGridType struct {
+	Type int `xml:"type,attr"`
+}
+
+// The voxels are listed with x changing fastest, then y, then z, as '#' for
+// solid, '+' for variable and '_' for empty, each optionally followed by the
+// number of its color. The hotspot is the voxel that an assembly places at
+// the piece's position.
+type xmVoxel struct {
+	X       int    `xml:"x,attr"`
+	Y       int    `xml:"y,attr"`
+	Z       int    `xml:"z,attr"`
+	HX      int    `xml:"hx,attr,omitempty"`
+	HY      int    `xml:"hy,attr,omitempty"`
+	HZ      int    `xml:"hz,attr,omitempty"`
+	Type    int    `xml:"type,attr"`
+	Name    string `xml:"name,attr,omitempty"`
+	Content string `xml:",chardata"`
+}
+
+type xmProblem struct {
+	Name          string           `xml:"name,attr,omitempty"`
+	State         int              `xml:"state,attr"`
+	NumAssemblies int              `xml:"assemblies,attr"`
+	NumSolutions  int              `xml:"solutions,attr"`
+	Shapes        []xmProblemShape `xml:"shapes>shape"`
+	Result        xmResult         `xml:"result"`
+	Solutions     []xmSolution     `xml:"solutions>solution"`
+}
+
+// Older files give the range of the number of copies of a shape instead of
+// the count.
+type xmProblemShape struct {
+	ID    int `xml:"id,attr"`
+	Count int `xml:"count,attr,omitempty"`
+	Min   int `xml:"min,attr,omitempty"`
+	Max   int `xml:"max,attr,omitempty"`
+}
+
+type xmResult struct {
+	ID int `xml:"id,attr"`
+}
+
+// The assembly lists "x y z transformation" for each piece of the problem,
+// or "x" for a piece that is not used.
+type xmSolution struct {
+	Assembly string `xml:"assembly"`
+}
+
+// BurrTools' problem state for a problem that has been solved.
+const xmSolved = 2
+
+// The rotations of BurrTools' transformations 0 to 23, each made of quarter
+// turns about x, then y, then z. Transformations 24 to 47 mirror the shape
+// first.
+var burrRotations = func() (rotations [24]TransformMatrix) {
+	turnsX := [24]int{0, 1, 2, 3, 0, 1, 2, 3, 0, 1, 2, 3, 0, 1, 2, 3, 0, 1, 2, 3, 0, 1, 2, 3}
+	turnsY := [24]int{0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 0, 0, 0, 0, 0, 0, 0, 0}
+	turnsZ := [24]int{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 3, 3, 3, 3}
+	for i := range rotations {
+		rotations[i] = Translation{}.TransformMatrix()
+		for axis, turns := range [3]int{turnsX[i], turnsY[i], turnsZ[i]} {
+			turn := Rotation{Axis(axis), 1, Cell{}}.TransformMatrix()
+			for j := 0; j < turns; j++ {
+				rotations[i] = turn.multiply(&rotations[i])
+			}
+		}
+	}
+	return
+}()
+
+// The ANSI escape colors given to the pieces of BurrTools puzzles, in order.
+// Pieces beyond these get IDs from firstPlainID upwards, which are not colors.
+var burrColors = []uint8{31, 32, 33, 34, 35, 36, 91, 92, 93, 94, 95, 96}
+
+const firstPlainID = 100
+
+// Reads piece definitions from a BurrTools file, gzipped or not. The pieces
+// are named after their shapes. A piece whose shape's name ends in an ID in
+// brackets, as WriteBurrTools writes them, gets that ID; the others are
+// colored in the order they are listed in the problem, skipping those IDs.
+// The definitions are not validated; see ValidateDefinitions.
+func LoadBurrTools(r io.Reader) ([]PieceDefinition, error) {
+	reader := bufio.NewReader(r)
+	if magic, err := reader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot read BurrTools file: %v", err)
+		}
+		defer gzipReader.Close()
+		r = gzipReader
+	} else {
+		r = reader
+	}
+	var file xmPuzzle
+	if err := xml.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("Cannot read BurrTools file: %v", err)
+	}
+	if file.GridType.Type != 0 {
+		return nil, fmt.Errorf("BurrTools grid type %v is not supported; only cubes are.", file.GridType.Type)
+	}
+	if len(file.Problems) == 0 {
+		return nil, fmt.Errorf("BurrTools file has no problems.")
+	}
+	problem := file.Problems[0]
+	if len(problem.Solutions) == 0 {
+		return nil, fmt.Errorf("BurrTools problem has no assembly to place the pieces.")
+	}
+	placements := strings.Fields(problem.Solutions[0].Assembly)
+
+	var defns []PieceDefinition
+	// Whether each piece got its ID from its shape's name.
+	var hasID []bool
+	for _, problemShape := range problem.Shapes {
+		if problemShape.ID < 0 || problemShape.ID >= len(file.Shapes) {
+			return nil, fmt.Errorf("BurrTools problem has unknown shape %v.", problemShape.ID)
+		}
+		shape := file.Shapes[problemShape.ID]
+		name := shape.Name
+		if name == "" {
+			// As named in BurrTools.
+			name = fmt.Sprintf("S%v", problemShape.ID+1)
+		}
+		voxels, err := shape.voxels(name)
+		if err != nil {
+			return nil, err
+		}
+		count := problemShape.Count
+		if count == 0 {
+			count = problemShape.Max
+		}
+		for i := 0; i < count; i++ {
+			if len(placements) == 0 {
+				return nil, fmt.Errorf("BurrTools assembly has too few pieces.")
+			}
+			if placements[0] == "x" {
+				placements = placements[1:]
+				continue
+			}
+			if len(placements) < 4 {
+				return nil, fmt.Errorf("BurrTools assembly has too few pieces.")
+			}
+			var numbers [4]int
+			for j := range numbers {
+				if numbers[j], err = strconv.Atoi(placements[j]); err != nil {
+					return nil, fmt.Errorf("BurrTools assembly has %q instead of a number.", placements[j])
+				}
+			}
+			placements = placements[4:]
+			if numbers[3] < 0 || numbers[3] >= 2*len(burrRotations) {
+				return nil, fmt.Errorf("BurrTools piece %v has unknown transformation %v.", name, numbers[3])
+			}
+			if numbers[3] >= len(burrRotations) {
+				return nil, fmt.Errorf("BurrTools piece %v is mirrored, which is not supported.", name)
+			}
+			defn := PieceDefinition{Name: name, Voxels: voxels}
+			ok := false
+			if count > 1 {
+				defn.Name = fmt.Sprintf("%v %v", name, i+1)
+			} else {
+				defn.Name, defn.EscColor, ok = splitBurrName(name)
+			}
+			// The turned hotspot is placed at the position.
+			defn.Transform = burrRotations[numbers[3]]
+			hotspot := Cell{shape.HX, shape.HY, shape.HZ}.transform(&defn.Transform)
+			for j := 0; j < 3; j++ {
+				defn.Transform[j][3] = numbers[j] - hotspot[j]
+			}
+			defns = append(defns, defn)
+			hasID = append(hasID, ok)
+		}
+	}
+	if len(placements) > 0 {
+		return nil, fmt.Errorf("BurrTools assembly has too many pieces.")
+	}
+	if len(defns) == 0 {
+		return nil, fmt.Errorf("BurrTools problem has no pieces.")
+	}
+	if err := colorBurrPieces(defns, hasID); err != nil {
+		return nil, err
+	}
+	return defns, nil
+}
+
+// Splits the name of a shape written by WriteBurrTools into the piece's name
+// and ID. ok is false if the name does not end in an ID in brackets.
+func splitBurrName(shapeName string) (name string, id uint8, ok bool) {
+	i := strings.LastIndex(shapeName, " (")
+	if i < 0 || !strings.HasSuffix(shapeName, ")") {
+		return shapeName, 0, false
+	}
+	number, err := strconv.ParseUint(shapeName[i+2:len(shapeName)-1], 10, 8)
+	if err != nil {
+		return shapeName, 0, false
+	}
+	return shapeName[:i], uint8(number), true
+}
+
+// Gives the pieces without an ID the colors of burrColors in order, then IDs
+// from firstPlainID upwards, skipping the IDs the other pieces have.
+func colorBurrPieces(defns []PieceDefinition, hasID []bool) error {
+	used := make(map[uint8]bool, len(defns))
+	for i, defn := range defns {
+		if hasID[i] {
+			used[defn.EscColor] = true
+		}
+	}
+	numColors := len(burrColors) + 256 - firstPlainID
+	next := 0
+	for i := range defns {
+		if hasID[i] {
+			continue
+		}
+		for next < numColors && used[burrColor(next)] {
+			next++
+		}
+		if next == numColors {
+			return fmt.Errorf("BurrTools problem has too many pieces.")
+		}
+		defns[i].EscColor = burrColor(next)
+		next++
+	}
+	return nil
+}
+
+// The i-th of the colors given to pieces without an ID.
+func burrColor(i int) uint8 {
+	if i < len(burrColors) {
+		return burrColors[i]
+	}
+	return uint8(firstPlainID + i - len(burrColors))
+}
+
+// Returns the solid and variable voxels of the shape.
+func (shape xmVoxel) voxels(name string) (Cells, error) {
+	if shape.X <= 0 || shape.Y <= 0 || shape.Z <= 0 {
+		return nil, fmt.Errorf("BurrTools shape %v has size %vx%vx%v.", name, shape.X, shape.Y, shape.Z)
+	}
+	voxels := make(Cells, 0)
+	i := 0
+	for _, c := range shape.Content {
+		switch {
+		case c >= '0' && c <= '9':
+			// The color of the previous voxel.
+			continue
+		case c == '#' || c == '+':
+			voxels = append(voxels, Cell{i % shape.X, i / shape.X % shape.Y, i / shape.X / shape.Y})
+		case c == '_':
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			continue
+		default:
+			return nil, fmt.Errorf("BurrTools shape %v has %q; must be '#', '+' or '_'.", name, c)
+		}
+		i++
+	}
+	if i != shape.X*shape.Y*shape.Z {
+		return nil, fmt.Errorf("BurrTools shape %v has %v voxels, expected %v.", name, i, shape.X*shape.Y*shape.Z)
+	}
+	return voxels, nil
+}
+
+// Reads a puzzle from a BurrTools file, with its pieces assembled.
+func LoadBurrToolsPuzzle(r io.Reader) (*Puzzle, error) {
+	defns, err := LoadBurrTools(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewPuzzleFrom(defns...)
+}
+
+// Writes the pieces, placed as they are, to a gzipped BurrTools file. Each
+// piece gets its own shape, and the result shape is all of the pieces put
+// together.
+func WriteBurrTools(w io.Writer, defns ...PieceDefinition) error {
+	puzzle, err := NewPuzzleFrom(defns...)
+	if err != nil {
+		return err
+	}
+	return puzzle.WriteBurrTools(w)
+}
+
+// Writes the puzzle in its current state to a gzipped BurrTools file. The
+// pieces are listed by ID, and their shapes are named after them followed by
+// their IDs in brackets.
+func (puzzle *Puzzle) WriteBurrTools(w io.Writer) error {
+	file := xmPuzzle{Version: 2}
+	var shapeIDs, assembly []string
+	minX, minY, minZ := puzzle.minCoords()
+	for i, piece := range puzzle.sortedPieces() {
+		cells := piece.Definition.Cells()
+		var shapeMin Cell
+		for axis := X; axis <= Z; axis++ {
+			shapeMin[axis], _ = cells.span(axis)
+		}
+		for j := range cells {
+			for axis := X; axis <= Z; axis++ {
+				cells[j][axis] -= shapeMin[axis]
+			}
+		}
+		file.Shapes = append(file.Shapes,
+			newXMVoxel(fmt.Sprintf("%v (%v)", piece.Definition.Name, piece.Definition.EscColor), cells))
+
+		// Moving the shape to start at the origin moves where it is placed.
+		rotation := piece.Transform
+		for j := 0; j < 3; j++ {
+			rotation[j][3] = 0
+		}
+		position := shapeMin.transform(&rotation)
+		transformation := -1
+		for j, burrRotation := range burrRotations {
+			if burrRotation == rotation {
+				transformation = j
+			}
+		}
+		if transformation < 0 {
+			return &InvalidTransformError{piece.Definition.Name, piece.Transform}
+		}
+		assembly = append(assembly, fmt.Sprintf("%v %v %v %v",
+			position[X]+piece.Transform[X][3]-minX,
+			position[Y]+piece.Transform[Y][3]-minY,
+			position[Z]+piece.Transform[Z][3]-minZ,
+			transformation))
+		shapeIDs = append(shapeIDs, strconv.Itoa(i))
+	}
+
+	result := make(Cells, 0, len(puzzle.CellMap))
+	for cell := range puzzle.CellMap {
+		result = append(result, Cell{cell[X] - minX, cell[Y] - minY, cell[Z] - minZ})
+	}
+	file.Shapes = append(file.Shapes, newXMVoxel("Result", result))
+
+	problem := xmProblem{
+		State:         xmSolved,
+		NumAssemblies: 1,
+		NumSolutions:  1,
+		Result:        xmResult{len(file.Shapes) - 1},
+		Solutions:     []xmSolution{{strings.Join(assembly, " ")}},
+	}
+	for i := range shapeIDs {
+		problem.Shapes = append(problem.Shapes, xmProblemShape{ID: i, Count: 1})
+	}
+	file.Problems = []xmProblem{problem}
+
+	gzipWriter := gzip.NewWriter(w)
+	if _, err := io.WriteString(gzipWriter, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(gzipWriter)
+	encoder.Indent("", " ")
+	if err := encoder.Encode(file); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+// Returns the shape of the cells, which must have no negative coordinates.
+func newXMVoxel(name string, cells Cells) xmVoxel {
+	var size Cell
+	for _, cell := range cells {
+		for axis := X; axis <= Z; axis++ {
+			if cell[axis]+1 > size[axis] {
+				size[axis] = cell[axis] + 1
+			}
+		}
+	}
+	content := []byte(strings.Repeat("_", size[X]*size[Y]*size[Z]))
+	for _, cell := range cells {
+		content[cell[X]+size[X]*(cell[Y]+size[Y]*cell[Z])] = '#'
+	}
+	return xmVoxel{X: size[X], Y: size[Y], Z: size[Z], Name: name, Content: string(content)}
+}
@@ -0,0 +1,175 @@
+package gknot
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// The cup and peg of testdata/cup.json, as saved by BurrTools, with the peg
+// turned twice about x. The cup has a color on one of its voxels.
+const cupXMPuzzle = `<?xml version="1.0"?>
+<puzzle version="2">
+ <gridType type="0"/>
+ <colors/>
+ <shapes>
+  <voxel x="3" y="3" z="3" type="0" name="Cup">#########1####_##_##########</voxel>
+  <voxel x="1" y="2" z="1" type="0">##</voxel>
+  <voxel x="3" y="3" z="3" type="0">###########################</voxel>
+ </shapes>
+ <problems>
+  <problem state="2" assemblies="1" solutions="1" time="0">
+   <shapes>
+    <shape id="0" count="1"/>
+    <shape id="1" min="0" max="2"/>
+   </shapes>
+   <result id="2"/>
+   <bitmap/>
+   <solutions>
+    <solution asmNum="0">
+     <assembly>0 0 0 0 1 2 1 2 x</assembly>
+    </solution>
+   </solutions>
+  </problem>
+ </problems>
+ <comment/>
+</puzzle>`
+
+func TestLoadBurrTools(t *testing.T) {
+	defns, err := LoadBurrTools(strings.NewReader(cupXMPuzzle))
+	if err != nil {
+		t.Fatalf("LoadBurrTools returned error %v.", err)
+	}
+	if len(defns) != 2 {
+		t.Fatalf("Expected 2 pieces, actual %v.", len(defns))
+	}
+	if defns[0].Name != "Cup" || defns[0].EscColor != 31 || defns[1].Name != "S2 1" || defns[1].EscColor != 32 {
+		t.Fatalf("Expected pieces Cup 31 and S2 1 32, actual %v %v and %v %v.",
+			defns[0].Name, defns[0].EscColor, defns[1].Name, defns[1].EscColor)
+	}
+	puzzle, err := NewPuzzleFrom(defns...)
+	if err != nil {
+		t.Fatalf("NewPuzzleFrom returned error %v.", err)
+	}
+	if len(puzzle.CellMap) != 27 {
+		t.Fatalf("Pieces should fill a 3x3x3 cube, actual %v cells.", len(puzzle.CellMap))
+	}
+	// Turning twice about x takes the peg along y onto the peg along -y.
+	checkPiece(t, puzzle.Pieces[32], Cells{{1, 2, 1}, {1, 1, 1}})
+}
+
+// Shapes named as WriteBurrTools names them keep their IDs, and the other
+// pieces are colored skipping those IDs.
+func TestLoadBurrTools_ids(t *testing.T) {
+	file := strings.Replace(cupXMPuzzle, `name="Cup"`, `name="Cup (31)"`, 1)
+	file = strings.Replace(file, `<shape id="1" min="0" max="2"/>`, `<shape id="1" count="1"/>`, 1)
+	file = strings.Replace(file, `1 2 1 2 x`, `1 2 1 2`, 1)
+	for _, test := range []struct {
+		cupName, pegName string
+		cupID, pegID     uint8
+	}{
+		{"Cup (31)", "S2", 31, 32},
+		{"Cup (32)", "S2", 32, 31},
+		{"Cup (200)", "S2", 200, 31},
+		{"Cup (256)", "S2", 31, 32},
+		{"Cup (x)", "S2", 31, 32},
+	} {
+		file := strings.Replace(file, `name="Cup (31)"`, `name="`+test.cupName+`"`, 1)
+		defns, err := LoadBurrTools(strings.NewReader(file))
+		if err != nil {
+			t.Fatalf("LoadBurrTools returned error %v.", err)
+		}
+		cupName := strings.TrimSuffix(test.cupName, fmt.Sprintf(" (%v)", test.cupID))
+		if defns[0].Name != cupName || defns[0].EscColor != test.cupID ||
+			defns[1].Name != test.pegName || defns[1].EscColor != test.pegID {
+			t.Errorf("Shape %v should give pieces %v %v and %v %v, actual %v %v and %v %v.", test.cupName,
+				cupName, test.cupID, test.pegName, test.pegID,
+				defns[0].Name, defns[0].EscColor, defns[1].Name, defns[1].EscColor)
+		}
+	}
+}
+
+// testdata/cup.xmpuzzle is the cup and peg, written by hand in the layout
+// BurrTools saves files in and gzipped, with a custom color and with the
+// hotspot of the peg at its second voxel.
+func TestLoadBurrTools_hotspot(t *testing.T) {
+	puzzle, err := LoadPuzzleFile("testdata/cup.xmpuzzle")
+	if err != nil {
+		t.Fatalf("LoadPuzzleFile returned error %v.", err)
+	}
+	if len(puzzle.CellMap) != 27 {
+		t.Fatalf("Pieces should fill a 3x3x3 cube, actual %v cells.", len(puzzle.CellMap))
+	}
+	// Turned twice about x, the peg's hotspot is placed at (1, 1, 1) and its
+	// first voxel above it.
+	checkPiece(t, puzzle.Pieces[32], Cells{{1, 2, 1}, {1, 1, 1}})
+}
+
+func TestLoadBurrTools_errors(t *testing.T) {
+	for _, test := range []struct {
+		old, new string
+		errMsg   string
+	}{
+		{`<gridType type="0"/>`, `<gridType type="1"/>`, "BurrTools grid type 1 is not supported; only cubes are."},
+		{`type="0">##<`, `type="0">#.<`, `BurrTools shape S2 has '.'; must be '#', '+' or '_'.`},
+		{`type="0">##<`, `type="0">#<`, "BurrTools shape S2 has 1 voxels, expected 2."},
+		{`1 2 1 2 x`, `1 2 1 26 x`, "BurrTools piece S2 is mirrored, which is not supported."},
+		{`1 2 1 2 x`, `1 2 1 2`, "BurrTools assembly has too few pieces."},
+		{`1 2 1 2 x`, `1 2 1 2 x x`, "BurrTools assembly has too many pieces."},
+		{`<shape id="1"`, `<shape id="3"`, "BurrTools problem has unknown shape 3."},
+	} {
+		file := strings.Replace(cupXMPuzzle, test.old, test.new, 1)
+		if _, err := LoadBurrTools(strings.NewReader(file)); err == nil || err.Error() != test.errMsg {
+			t.Errorf("Replacing %v with %v should fail with '%v', actual %v.", test.old, test.new, test.errMsg, err)
+		}
+	}
+}
+
+func TestBurrRotations(t *testing.T) {
+	seen := make(map[TransformMatrix]bool)
+	for i, rotation := range burrRotations {
+		if !rotation.isRigidMotion() {
+			t.Fatalf("Transformation %v should be a rotation, actual %v.", i, rotation)
+		}
+		if seen[rotation] {
+			t.Fatalf("Transformation %v repeats an earlier one, %v.", i, rotation)
+		}
+		seen[rotation] = true
+	}
+}
+
+func TestWriteBurrTools_gordianKnot(t *testing.T) {
+	// Colors other than those the pieces would get in order, so that they
+	// only come back if the IDs are kept.
+	colors := map[uint8]uint8{31: 96, 32: 95, 33: 94, 34: 93, 35: 92, 36: 200}
+	var defns []PieceDefinition
+	for _, defn := range []PieceDefinition{BluePieceDef, OrangePieceDef, PurplePieceDef, GreenPieceDef,
+		RedPieceDef, YellowPieceDef} {
+		defn.EscColor = colors[defn.EscColor]
+		defns = append(defns, defn)
+	}
+	start, err := NewPuzzleFrom(defns...)
+	if err != nil {
+		t.Fatalf("NewPuzzleFrom returned error %v.", err)
+	}
+	var buf bytes.Buffer
+	if err := start.WriteBurrTools(&buf); err != nil {
+		t.Fatalf("WriteBurrTools returned error %v.", err)
+	}
+	puzzle, err := LoadBurrToolsPuzzle(&buf)
+	if err != nil {
+		t.Fatalf("LoadBurrToolsPuzzle returned error %v.", err)
+	}
+	if len(puzzle.CellMap) != len(start.CellMap) {
+		t.Fatalf("Expected %v cells, actual %v.", len(start.CellMap), len(puzzle.CellMap))
+	}
+	for cell, piece := range start.CellMap {
+		loadedPiece, ok := puzzle.CellMap[cell]
+		if !ok || loadedPiece.Definition.EscColor != piece.Definition.EscColor ||
+			loadedPiece.Definition.Name != piece.Definition.Name {
+			t.Fatalf("Cell %v should be in the %v piece with ID %v, actual %v.", cell, piece.Definition.Name,
+				piece.Definition.EscColor, loadedPiece)
+		}
+	}
+}
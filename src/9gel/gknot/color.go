@@ -0,0 +1,67 @@
+// The colours pieces are drawn in pictures.
+package gknot
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// The colours of the pieces of the Gordian Knot, which are named after them.
+var pieceColors = map[string]color.RGBA{
+	"Blue":   {0x1e, 0x5a, 0xc8, 0xff},
+	"Orange": {0xf0, 0x82, 0x1e, 0xff},
+	"Purple": {0x82, 0x3c, 0xb4, 0xff},
+	"Green":  {0x2d, 0xa0, 0x3c, 0xff},
+	"Red":    {0xd2, 0x28, 0x28, 0xff},
+	"Yellow": {0xf5, 0xd2, 0x1e, 0xff},
+}
+
+// The usual colours of the ANSI escape colours 30 to 37, and of their bright
+// versions 90 to 97.
+var escColors = [8]color.RGBA{
+	{0x00, 0x00, 0x00, 0xff},
+	{0xcd, 0x00, 0x00, 0xff},
+	{0x00, 0xcd, 0x00, 0xff},
+	{0xcd, 0xcd, 0x00, 0xff},
+	{0x00, 0x00, 0xee, 0xff},
+	{0xcd, 0x00, 0xcd, 0xff},
+	{0x00, 0xcd, 0xcd, 0xff},
+	{0xe5, 0xe5, 0xe5, 0xff},
+}
+
+var brightEscColors = [8]color.RGBA{
+	{0x7f, 0x7f, 0x7f, 0xff},
+	{0xff, 0x00, 0x00, 0xff},
+	{0x00, 0xff, 0x00, 0xff},
+	{0xff, 0xff, 0x00, 0xff},
+	{0x5c, 0x5c, 0xff, 0xff},
+	{0xff, 0x00, 0xff, 0xff},
+	{0x00, 0xff, 0xff, 0xff},
+	{0xff, 0xff, 0xff, 0xff},
+}
+
+// The colour to draw the piece in: the colour the piece is named after if it
+// is one of the Gordian Knot's, or else the colour of its ANSI escape colour,
+// or grey for other IDs.
+func (pieceDefn PieceDefinition) Color() color.RGBA {
+	if c, ok := pieceColors[pieceDefn.Name]; ok {
+		return c
+	}
+	switch {
+	case pieceDefn.EscColor >= 30 && pieceDefn.EscColor <= 37:
+		return escColors[pieceDefn.EscColor-30]
+	case pieceDefn.EscColor >= 90 && pieceDefn.EscColor <= 97:
+		return brightEscColors[pieceDefn.EscColor-90]
+	}
+	return color.RGBA{0x99, 0x99, 0x99, 0xff}
+}
+
+// Scales the colour's red, green and blue by factor, which is at most 1.
+func darken(c color.RGBA, factor float64) color.RGBA {
+	return color.RGBA{uint8(float64(c.R) * factor), uint8(float64(c.G) * factor), uint8(float64(c.B) * factor), c.A}
+}
+
+// The colour in the #rrggbb form of HTML and SVG.
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
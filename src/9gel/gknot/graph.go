@@ -0,0 +1,287 @@
+// The graph of the states reachable from a puzzle, and exporting it to graph
+// tools as Graphviz DOT or GraphML.
+package gknot
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A state of a StateGraph.
+type StateNode struct {
+	Key StateKey
+	// The state ID, for display.
+	ID StateID
+	// The fewest moves that reach the state from the start.
+	Depth int
+	// Whether a group of pieces is free in the state, to be taken off by
+	// Removal. The states beyond are not explored.
+	Separated bool
+	Removal   Move
+	// Whether the state was explored and every move from it leads back to the
+	// same state.
+	DeadEnd bool
+}
+
+// Two states joined by a move, given by their indices in StateGraph.Nodes.
+type StateEdge struct {
+	From int
+	To   int
+	Move Move
+}
+
+// The graph of the states reachable from a puzzle without taking off any
+// pieces.
+type StateGraph struct {
+	// Nodes[0] is the starting state. The states are listed breadth first.
+	Nodes []StateNode
+	// Each pair of states joined by a move is listed once, with the first move
+	// found between them.
+	Edges []StateEdge
+	// Solved if every reachable state is in the graph.
+	Reason StopReason
+	// The names of the pieces, by ID.
+	pieceNames map[uint8]string
+}
+
+// Explores the states reachable from the puzzle breadth first, stopping at
+// states in which a group of pieces is free. The options limit and choose the
+// moves as for SolveContext; the goal and workers are ignored.
+func (puzzle *Puzzle) StateGraph(ctx context.Context, opts SolveOptions) (*StateGraph, error) {
+	s, cancel, err := puzzle.newSolver(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	graph := &StateGraph{pieceNames: make(map[uint8]string, len(puzzle.Pieces))}
+	for pieceID, piece := range puzzle.Pieces {
+		graph.pieceNames[pieceID] = piece.Definition.Name
+	}
+	indices := make(map[StateKey]int)
+	var puzzles []*Puzzle
+	addNode := func(p *Puzzle, key StateKey, depth int) int {
+		indices[key] = len(graph.Nodes)
+		graph.Nodes = append(graph.Nodes, StateNode{Key: key, ID: p.StateID(), Depth: depth})
+		puzzles = append(puzzles, p)
+		s.solution.Stats.StatesVisited++
+		if s.solution.Stats.StatesVisited%progressInterval == 0 {
+			s.report(0, depth)
+		}
+		return indices[key]
+	}
+	addNode(puzzle, puzzle.Key(), 0)
+	edges := make(map[[2]int]bool)
+	for i := 0; i < len(graph.Nodes) && !s.stopped; i++ {
+		current := puzzles[i]
+		moves := s.moves(current, graph.Nodes[i].Key)
+		if removal, ok := current.freeGroup(moves); ok {
+			graph.Nodes[i].Separated, graph.Nodes[i].Removal = true, removal
+			continue
+		}
+		if !s.canMove(graph.Nodes[i].Depth) {
+			continue
+		}
+		neighbours := make(map[int]bool)
+		for _, move := range moves {
+			next := current.Mutate(move.Mutations()...)
+			move.To = next.Key()
+			j, ok := indices[move.To]
+			if !ok {
+				if s.limitReached() {
+					break
+				}
+				j = addNode(next, move.To, graph.Nodes[i].Depth+1)
+			}
+			neighbours[j] = true
+			edge := [2]int{i, j}
+			if j < i {
+				edge = [2]int{j, i}
+			}
+			if !edges[edge] {
+				edges[edge] = true
+				graph.Edges = append(graph.Edges, StateEdge{i, j, move})
+			}
+		}
+		graph.Nodes[i].DeadEnd = !s.stopped && len(neighbours) <= 1
+	}
+	s.setReason(!s.stopped && !s.depthLimited)
+	graph.Reason = s.solution.Reason
+	return graph, nil
+}
+
+// Describes the move for people, for example "Orange Blue +x by 2" for a
+// slide or "Red turn -z" for a clockwise quarter turn about the z axis.
+func (graph *StateGraph) Describe(move Move) string {
+	return describeMove(move, graph.pieceNames)
+}
+
+// Describes a move made on the puzzle as StateGraph.Describe does.
+func (puzzle *Puzzle) Describe(move Move) string {
+	pieceNames := make(map[uint8]string, len(puzzle.Pieces))
+	for pieceID, piece := range puzzle.Pieces {
+		pieceNames[pieceID] = piece.Definition.Name
+	}
+	return describeMove(move, pieceNames)
+}
+
+func describeMove(move Move, pieceNames map[uint8]string) string {
+	names := make([]string, len(move.PieceIDs))
+	for i, pieceID := range move.PieceIDs {
+		names[i] = pieceNames[pieceID]
+	}
+	var direction string
+	switch {
+	case move.Rotation.Turn != 0:
+		direction = "turn " + axisDirection(move.Rotation.Axis, move.Rotation.Turn)
+	default:
+		for axis, v := range move.Translation {
+			if v != 0 {
+				direction = axisDirection(Axis(axis), v)
+			}
+		}
+		if distance := move.Distance(); distance > 1 {
+			direction += fmt.Sprintf(" by %v", distance)
+		}
+	}
+	return strings.Join(names, " ") + " " + direction
+}
+
+// The axis prefixed by the sign of v, such as "-y".
+func axisDirection(axis Axis, v int) string {
+	if v < 0 {
+		return "-" + "xyz"[axis:axis+1]
+	}
+	return "+" + "xyz"[axis:axis+1]
+}
+
+// The kind of a node, which tools may highlight it by.
+func (graph *StateGraph) kind(i int) string {
+	switch {
+	case i == 0:
+		return "start"
+	case graph.Nodes[i].Separated:
+		return "separated"
+	case graph.Nodes[i].DeadEnd:
+		return "dead end"
+	}
+	return "state"
+}
+
+// The fill colors of the kinds of nodes in DOT graphs.
+var dotColors = map[string]string{
+	"start":     "lightblue",
+	"separated": "gold",
+	"dead end":  "salmon",
+	"state":     "white",
+}
+
+// Writes the graph in the Graphviz DOT language. The starting state, separated
+// states and dead ends are filled in different colors.
+func (graph *StateGraph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("graph states {\n")
+	b.WriteString("\tnode [shape=box, style=filled, fontname=monospace];\n")
+	for i, node := range graph.Nodes {
+		fmt.Fprintf(&b, "\ts%v [label=%v, fillcolor=%v", i, dotQuote(string(node.ID)), dotColors[graph.kind(i)])
+		if node.Separated {
+			fmt.Fprintf(&b, ", tooltip=%v", dotQuote("Remove "+graph.Describe(node.Removal)))
+		}
+		b.WriteString("];\n")
+	}
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(&b, "\ts%v -- s%v [label=%v];\n", edge.From, edge.To, dotQuote(graph.Describe(edge.Move)))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Quotes the string as a DOT ID.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Writes the graph as GraphML. Each state has its ID as label, its depth, and
+// its kind: "start", "separated", "dead end" or "state". Separated states
+// also describe their removal, and edges their move.
+func (graph *StateGraph) WriteGraphML(w io.Writer) error {
+	file := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{"label", "node", "label", "string"},
+			{"depth", "node", "depth", "int"},
+			{"kind", "node", "kind", "string"},
+			{"removal", "node", "removal", "string"},
+			{"move", "edge", "move", "string"},
+		},
+		Graph: graphMLGraph{ID: "states", EdgeDefault: "undirected"},
+	}
+	for i, node := range graph.Nodes {
+		data := []graphMLData{
+			{"label", string(node.ID)},
+			{"depth", fmt.Sprint(node.Depth)},
+			{"kind", graph.kind(i)},
+		}
+		if node.Separated {
+			data = append(data, graphMLData{"removal", graph.Describe(node.Removal)})
+		}
+		file.Graph.Nodes = append(file.Graph.Nodes, graphMLNode{fmt.Sprintf("s%v", i), data})
+	}
+	for _, edge := range graph.Edges {
+		file.Graph.Edges = append(file.Graph.Edges, graphMLEdge{
+			fmt.Sprintf("s%v", edge.From),
+			fmt.Sprintf("s%v", edge.To),
+			[]graphMLData{{"move", graph.Describe(edge.Move)}},
+		})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(file); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
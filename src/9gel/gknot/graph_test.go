@@ -0,0 +1,129 @@
+package gknot
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+// A cube in a closed box two cells long.
+const boxedCubeFile = `{"pieces": [
+	{"name": "Box", "color": 33, "layers": [
+		["###", "###", "###", "###"],
+		["###", "#.#", "#.#", "###"],
+		["###", "###", "###", "###"]],
+	 "transform": [[1, 0, 0, 0], [0, 1, 0, 0], [0, 0, 1, 0], [0, 0, 0, 1]]},
+	{"name": "Cube", "color": 31, "geom": ["#"],
+	 "transform": [[1, 0, 0, 1], [0, 1, 0, 1], [0, 0, 1, 1], [0, 0, 0, 1]]}]}`
+
+func boxedCubeGraph(t *testing.T) *StateGraph {
+	puzzle, err := LoadPuzzle(strings.NewReader(boxedCubeFile))
+	if err != nil {
+		t.Fatalf("LoadPuzzle returned error %v.", err)
+	}
+	graph, err := puzzle.StateGraph(context.Background(), SolveOptions{})
+	if err != nil {
+		t.Fatalf("StateGraph returned error %v.", err)
+	}
+	return graph
+}
+
+func TestStateGraph(t *testing.T) {
+	graph := boxedCubeGraph(t)
+	if graph.Reason != Solved {
+		t.Fatalf("Every state should be in the graph, actual %v.", graph.Reason)
+	}
+	// The cube at either end of the box, whichever of the two pieces moves.
+	if len(graph.Nodes) != 2 || len(graph.Edges) != 1 {
+		t.Fatalf("Expected 2 states and 1 edge, actual %v and %v.", graph.Nodes, graph.Edges)
+	}
+	for i, node := range graph.Nodes {
+		if node.Depth != i || !node.DeadEnd || node.Separated {
+			t.Fatalf("State %v should be a dead end at depth %v, actual %v.", i, i, node)
+		}
+	}
+	if edge := graph.Edges[0]; edge.From != 0 || edge.To != 1 || graph.Describe(edge.Move) != "Cube +y" {
+		t.Fatalf("Edge should move the cube +y from state 0 to 1, actual %v.", graph.Describe(edge.Move))
+	}
+}
+
+func TestStateGraph_gordianKnot(t *testing.T) {
+	graph, err := NewPuzzle().StateGraph(context.Background(), SolveOptions{MaxDepth: 2})
+	if err != nil {
+		t.Fatalf("StateGraph returned error %v.", err)
+	}
+	if graph.Reason != MaxDepthReached {
+		t.Fatalf("Graph should stop at the depth limit, actual %v.", graph.Reason)
+	}
+	for _, edge := range graph.Edges {
+		from, to := graph.Nodes[edge.From], graph.Nodes[edge.To]
+		if to.Depth > 2 || to.Depth < from.Depth-1 || to.Depth > from.Depth+1 {
+			t.Fatalf("Edge %v joins states at depths %v and %v.", edge, from.Depth, to.Depth)
+		}
+	}
+}
+
+func TestStateGraph_writeDOT(t *testing.T) {
+	graph := boxedCubeGraph(t)
+	var buf bytes.Buffer
+	if err := graph.WriteDOT(&buf); err != nil {
+		t.Fatalf("WriteDOT returned error %v.", err)
+	}
+	dot := buf.String()
+	for _, expected := range []string{
+		"graph states {\n",
+		`s0 [label="` + string(graph.Nodes[0].ID) + `", fillcolor=lightblue];`,
+		`s1 [label="` + string(graph.Nodes[1].ID) + `", fillcolor=salmon];`,
+		`s0 -- s1 [label="Cube +y"];`,
+	} {
+		if !strings.Contains(dot, expected) {
+			t.Fatalf("DOT should contain %q, actual:\n%v", expected, dot)
+		}
+	}
+}
+
+func TestStateGraph_writeGraphML(t *testing.T) {
+	graph := boxedCubeGraph(t)
+	var buf bytes.Buffer
+	if err := graph.WriteGraphML(&buf); err != nil {
+		t.Fatalf("WriteGraphML returned error %v.", err)
+	}
+	var file graphML
+	if err := xml.Unmarshal(buf.Bytes(), &file); err != nil {
+		t.Fatalf("GraphML should be valid XML, actual error %v.", err)
+	}
+	if len(file.Graph.Nodes) != 2 || len(file.Graph.Edges) != 1 {
+		t.Fatalf("Expected 2 nodes and 1 edge, actual %v.", buf.String())
+	}
+	if kind := file.Graph.Nodes[1].Data[2]; kind.Key != "kind" || kind.Value != "dead end" {
+		t.Fatalf("Second state should be a dead end, actual %v.", kind)
+	}
+	if move := file.Graph.Edges[0].Data[0].Value; move != "Cube +y" {
+		t.Fatalf("Edge should move the cube +y, actual %v.", move)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	graph := &StateGraph{pieceNames: map[uint8]string{31: "Red", 36: "Blue"}}
+	for _, test := range []struct {
+		move     Move
+		expected string
+	}{
+		{Move{PieceIDs: []uint8{31}, Translation: Translation{0, 0, -1}}, "Red -z"},
+		{Move{PieceIDs: []uint8{31, 36}, Translation: Translation{3, 0, 0}}, "Red Blue +x by 3"},
+		{Move{PieceIDs: []uint8{36}, Rotation: Rotation{Y, -1, Cell{}}}, "Blue turn -y"},
+	} {
+		if description := graph.Describe(test.move); description != test.expected {
+			t.Errorf("Expected %q, actual %q.", test.expected, description)
+		}
+	}
+}
+
+func TestPuzzle_describe(t *testing.T) {
+	move := Move{PieceIDs: []uint8{33, 35}, Translation: Translation{0, -2, 0}}
+	if description := NewPuzzle().Describe(move); description != "Yellow Orange -y by 2" {
+		t.Fatalf("Expected %q, actual %q.", "Yellow Orange -y by 2", description)
+	}
+}
@@ -0,0 +1,247 @@
+// Drawing isometric pictures of puzzles.
+package gknot
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"sort"
+)
+
+// How to draw an isometric picture of a puzzle.
+type ImageOptions struct {
+	// The corner the puzzle is seen from, as the sign of its x, y and z. A zero
+	// sign counts as positive, so the zero value sees the puzzle from the
+	// +x +y +z corner. The z axis points up.
+	Camera [3]int
+	// The length in pixels of a cell's edges along the page. 0 means 24.
+	CellSize int
+	// nil means a transparent background.
+	Background color.Color
+}
+
+var ErrInvalidImageOptions = errors.New(
+	"ImageOptions.Camera signs must be -1, 0 or 1, and ImageOptions.CellSize must not be negative.")
+
+const defaultCellSize = 24
+
+// How much light each pair of faces of a cube gets, by the axis the faces
+// are perpendicular to.
+var faceLight = [3]float64{0.8, 0.6, 1}
+
+// The cos 30° the x and y axes slope at.
+var isoSlope = math.Sqrt(3) / 2
+
+// The camera of ImageOptions.
+type isoCamera struct {
+	// Of the corner the puzzle is seen from.
+	signs    [3]int
+	cellSize float64
+}
+
+// Checks the options and returns their camera.
+func (opts ImageOptions) camera() (isoCamera, error) {
+	camera := isoCamera{cellSize: float64(opts.CellSize)}
+	for axis, sign := range opts.Camera {
+		if sign < -1 || sign > 1 {
+			return camera, ErrInvalidImageOptions
+		}
+		camera.signs[axis] = 1
+		if sign < 0 {
+			camera.signs[axis] = -1
+		}
+	}
+	if opts.CellSize < 0 {
+		return camera, ErrInvalidImageOptions
+	}
+	if opts.CellSize == 0 {
+		camera.cellSize = defaultCellSize
+	}
+	return camera, nil
+}
+
+// Where a point in 3-space is on the page, in pixels from where the origin is.
+// Looking from the camera towards the puzzle with z up, the page's right is
+// (-sy, sx, 0) and its up is (-sx sz, -sy sz, 2) / 2 in 3-space.
+func (camera isoCamera) project(x, y, z float64) (pageX, pageY float64) {
+	signs := camera.signs
+	pageX = (-float64(signs[Y])*x + float64(signs[X])*y) * isoSlope
+	pageY = (float64(signs[X]*signs[Z])*x+float64(signs[Y]*signs[Z])*y)/2 - z
+	return pageX * camera.cellSize, pageY * camera.cellSize
+}
+
+// A piece drawn moved by an offset, in cells, from where it is.
+type isoPiece struct {
+	*Piece
+	offset [3]float64
+}
+
+// The pieces of the puzzle where they are.
+func (puzzle *Puzzle) isoPieces() []isoPiece {
+	pieces := make([]isoPiece, 0, len(puzzle.Pieces))
+	for _, piece := range puzzle.sortedPieces() {
+		pieces = append(pieces, isoPiece{Piece: piece})
+	}
+	return pieces
+}
+
+// A rectangle on the page, in pixels from where the origin is.
+type pageBounds struct {
+	minX, minY, maxX, maxY float64
+}
+
+func emptyPageBounds() pageBounds {
+	return pageBounds{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+}
+
+// Grows the bounds to cover the pieces.
+func (camera isoCamera) extend(bounds *pageBounds, pieces []isoPiece) {
+	for _, piece := range pieces {
+		for _, cell := range piece.Cells {
+			for corner := 0; corner < 8; corner++ {
+				x, y := camera.project(
+					float64(cell[X]+corner&1)+piece.offset[X],
+					float64(cell[Y]+corner>>1&1)+piece.offset[Y],
+					float64(cell[Z]+corner>>2)+piece.offset[Z])
+				bounds.minX, bounds.minY = math.Min(bounds.minX, x), math.Min(bounds.minY, y)
+				bounds.maxX, bounds.maxY = math.Max(bounds.maxX, x), math.Max(bounds.maxY, y)
+			}
+		}
+	}
+}
+
+// The size of an image showing the bounds with a margin of a cell around
+// them.
+func (camera isoCamera) imageSize(bounds pageBounds) (width, height int) {
+	if bounds.minX > bounds.maxX {
+		bounds = pageBounds{}
+	}
+	margin := 2 * camera.cellSize
+	return int(math.Ceil(bounds.maxX - bounds.minX + margin)), int(math.Ceil(bounds.maxY - bounds.minY + margin))
+}
+
+// The face of a cube at a cell that is seen from the camera.
+type isoFace struct {
+	corners [4][2]float64
+	// Nearer the camera is larger.
+	depth float64
+	color color.RGBA
+}
+
+// Draws an isometric picture of the puzzle with each cell a cube shaded by
+// which way its faces point.
+func (puzzle *Puzzle) Image(opts ImageOptions) (*image.RGBA, error) {
+	camera, err := opts.camera()
+	if err != nil {
+		return nil, err
+	}
+	pieces := puzzle.isoPieces()
+	bounds := emptyPageBounds()
+	camera.extend(&bounds, pieces)
+	width, height := camera.imageSize(bounds)
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	if opts.Background != nil {
+		draw.Draw(img, img.Bounds(), image.NewUniform(opts.Background), image.Point{}, draw.Src)
+	}
+	camera.draw(img, camera.cellSize-bounds.minX, camera.cellSize-bounds.minY, pieces)
+	return img, nil
+}
+
+// Draws the pieces with the origin at the given point of the image.
+func (camera isoCamera) draw(img *image.RGBA, originX, originY float64, pieces []isoPiece) {
+	signs := camera.signs
+	// Faces against a cell that is drawn moved by the same offset are hidden.
+	type offsetCell struct {
+		Cell
+		offset [3]float64
+	}
+	drawn := make(map[offsetCell]bool)
+	for _, piece := range pieces {
+		for _, cell := range piece.Cells {
+			drawn[offsetCell{cell, piece.offset}] = true
+		}
+	}
+	var faces []isoFace
+	for _, piece := range pieces {
+		for _, cell := range piece.Cells {
+			for axis := X; axis <= Z; axis++ {
+				neighbour := cell
+				neighbour[axis] += signs[axis]
+				if drawn[offsetCell{neighbour, piece.offset}] {
+					continue
+				}
+				face := isoFace{color: darken(piece.Definition.Color(), faceLight[axis])}
+				// The corners of the face, going round it.
+				u, v := perpendicularAxes[axis][0], perpendicularAxes[axis][1]
+				for i, step := range [4][2]int{{0, 0}, {1, 0}, {1, 1}, {0, 1}} {
+					corner := cell
+					if signs[axis] > 0 {
+						corner[axis]++
+					}
+					corner[u] += step[0]
+					corner[v] += step[1]
+					x, y := camera.project(float64(corner[X])+piece.offset[X], float64(corner[Y])+piece.offset[Y],
+						float64(corner[Z])+piece.offset[Z])
+					face.corners[i] = [2]float64{x + originX, y + originY}
+				}
+				for i := range cell {
+					face.depth += float64(signs[i]) * (float64(cell[i]) + piece.offset[i])
+				}
+				faces = append(faces, face)
+			}
+		}
+	}
+	// Cubes at the same depth do not overlap on the page, so drawing from the
+	// back to the front hides what the camera cannot see.
+	sort.SliceStable(faces, func(i, j int) bool { return faces[i].depth < faces[j].depth })
+	for _, face := range faces {
+		// Draw the edges by filling the face in a darker colour, then filling
+		// it again slightly smaller.
+		fillPolygon(img, face.corners, darken(face.color, 0.6))
+		var inner [4][2]float64
+		for i, corner := range face.corners {
+			for j := range corner {
+				centre := (face.corners[0][j] + face.corners[2][j]) / 2
+				inner[i][j] = centre + (corner[j]-centre)*(1-1.5/camera.cellSize)
+			}
+		}
+		fillPolygon(img, inner, face.color)
+	}
+}
+
+// Fills the convex polygon with the corners given going round it, filling
+// the pixels whose centres are inside.
+func fillPolygon(img *image.RGBA, corners [4][2]float64, c color.RGBA) {
+	top, bottom := math.Inf(1), math.Inf(-1)
+	for _, corner := range corners {
+		top, bottom = math.Min(top, corner[1]), math.Max(bottom, corner[1])
+	}
+	for py := int(math.Floor(top)); py <= int(math.Ceil(bottom)); py++ {
+		y := float64(py) + 0.5
+		left, right := math.Inf(1), math.Inf(-1)
+		for i, a := range corners {
+			b := corners[(i+1)%len(corners)]
+			if (a[1] <= y) == (b[1] <= y) {
+				continue
+			}
+			x := a[0] + (y-a[1])/(b[1]-a[1])*(b[0]-a[0])
+			left, right = math.Min(left, x), math.Max(right, x)
+		}
+		for px := int(math.Ceil(left - 0.5)); float64(px)+0.5 < right; px++ {
+			img.SetRGBA(px, py, c)
+		}
+	}
+}
+
+// Writes an isometric picture of the puzzle as a PNG image.
+func (puzzle *Puzzle) WritePNG(w io.Writer, opts ImageOptions) error {
+	img, err := puzzle.Image(opts)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
@@ -0,0 +1,70 @@
+package gknot
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestImage(t *testing.T) {
+	puzzle := cubes(t, Cell{0, 0, 0})
+	img, err := puzzle.Image(ImageOptions{Background: color.White})
+	if err != nil {
+		t.Fatalf("Image returned error %v.", err)
+	}
+	// The cube is 2 cos 30° cells wide and 2 cells tall on the page, with a
+	// margin of a cell around it.
+	if size := img.Bounds().Size(); size.X != 90 || size.Y != 96 {
+		t.Fatalf("Expected a 90x96 image, actual %v.", size)
+	}
+	if c := img.RGBAAt(0, 0); c != (color.RGBA{0xff, 0xff, 0xff, 0xff}) {
+		t.Fatalf("Corner should be the background, actual %v.", c)
+	}
+	// The top face gets all the light.
+	if c := img.RGBAAt(44, 36); c != escColors[1] {
+		t.Fatalf("Top face should be %v, actual %v.", escColors[1], c)
+	}
+	// The faces facing +x and +y are to the lower left and right of it.
+	if c := img.RGBAAt(30, 60); c != darken(escColors[1], faceLight[X]) {
+		t.Fatalf("Face facing +x should be %v, actual %v.", darken(escColors[1], faceLight[X]), c)
+	}
+	if c := img.RGBAAt(60, 60); c != darken(escColors[1], faceLight[Y]) {
+		t.Fatalf("Face facing +y should be %v, actual %v.", darken(escColors[1], faceLight[Y]), c)
+	}
+
+	// Seen from below, the bottom face is seen where the top face was.
+	img, err = puzzle.Image(ImageOptions{Camera: [3]int{1, 1, -1}})
+	if err != nil {
+		t.Fatalf("Image returned error %v.", err)
+	}
+	if c := img.RGBAAt(0, 0); c != (color.RGBA{}) {
+		t.Fatalf("Corner should be transparent, actual %v.", c)
+	}
+	if c := img.RGBAAt(44, 60); c != escColors[1] {
+		t.Fatalf("Bottom face should be %v, actual %v.", escColors[1], c)
+	}
+}
+
+func TestImage_errors(t *testing.T) {
+	puzzle := cubes(t, Cell{0, 0, 0})
+	for _, opts := range []ImageOptions{{Camera: [3]int{2, 1, 1}}, {CellSize: -1}} {
+		if _, err := puzzle.Image(opts); err != ErrInvalidImageOptions {
+			t.Fatalf("Expected ErrInvalidImageOptions for %v, actual %v.", opts, err)
+		}
+	}
+}
+
+func TestWritePNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewPuzzle().WritePNG(&buf, ImageOptions{CellSize: 10}); err != nil {
+		t.Fatalf("WritePNG returned error %v.", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("WritePNG should write a PNG image, actual error %v.", err)
+	}
+	if size := img.Bounds().Size(); size.X == 0 || size.Y == 0 {
+		t.Fatalf("Image should not be empty, actual %v.", size)
+	}
+}
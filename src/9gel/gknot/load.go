@@ -0,0 +1,179 @@
+// Loading puzzle definitions from files.
+//
+// A puzzle file is JSON with a list of pieces. Each piece's geometry is given
+// as rows of '#' for solid cells and '.' for voids, listed like a PieceGeom
+// literal: the first row is y = 0 and the first character of a row is x = 0.
+// For example, the Blue piece of the Gordian Knot:
+//
+//	{
+//	  "name": "Gordian Knot",
+//	  "pieces": [
+//	    {
+//	      "name": "Blue",
+//	      "color": 36,
+//	      "geom": [
+//	        "#######",
+//	        "#.....#",
+//	        "##...##",
+//	        "#.#...#",
+//	        "####.##"
+//	      ],
+//	      "transform": [[1, 0, 0, 0], [0, 0, -1, 2], [0, 1, 0, 1], [0, 0, 0, 1]]
+//	    }
+//	  ]
+//	}
+//
+// The color is the ANSI escape color, and the transform places the piece in
+// 3-space as for PieceDefinition. A piece without a transform is placed as
+// defined, by the identity.
+//
+// Pieces that are not flat are given by "layers" instead of "geom": a list of
+// layers in the same form as "geom", the first one at z = 0. Such pieces, and
+// flat pieces larger than a PieceGeom, are loaded as Voxels.
+//
+// Puzzle files are JSON only. YAML is not supported, as the package has no
+// dependencies beyond the standard library; a YAML file has to be converted
+// to JSON first.
+package gknot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+type puzzleFile struct {
+	Name   string      `json:"name"`
+	Pieces []pieceFile `json:"pieces"`
+}
+
+type pieceFile struct {
+	Name      string           `json:"name"`
+	Color     uint8            `json:"color"`
+	Geom      []string         `json:"geom"`
+	Layers    [][]string       `json:"layers"`
+	Transform *TransformMatrix `json:"transform"`
+}
+
+var identity = TransformMatrix{
+	{1, 0, 0, 0},
+	{0, 1, 0, 0},
+	{0, 0, 1, 0},
+	{0, 0, 0, 1}}
+
+// Reads piece definitions from a puzzle file. The definitions are not
+// validated; see ValidateDefinitions.
+func LoadPieceDefinitions(r io.Reader) ([]PieceDefinition, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	var file puzzleFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("Cannot read puzzle file: %v", err)
+	}
+	if len(file.Pieces) == 0 {
+		return nil, fmt.Errorf("Puzzle file has no pieces.")
+	}
+	defns := make([]PieceDefinition, len(file.Pieces))
+	for i, piece := range file.Pieces {
+		defns[i] = PieceDefinition{Name: piece.Name, EscColor: piece.Color, Transform: identity}
+		if piece.Transform != nil {
+			defns[i].Transform = *piece.Transform
+		}
+		layers := piece.Layers
+		if piece.Geom != nil {
+			if layers != nil {
+				return nil, fmt.Errorf("Piece %v has both geom and layers.", piece.Name)
+			}
+			layers = [][]string{piece.Geom}
+		}
+		voxels := make(Cells, 0)
+		for z, layer := range layers {
+			for y, row := range layer {
+				// Characters, not bytes, count along x.
+				x := 0
+				for _, c := range row {
+					switch c {
+					case '#':
+						voxels = append(voxels, Cell{x, y, z})
+					case '.':
+					default:
+						return nil, fmt.Errorf("Piece %v has %q at (%v, %v, %v); must be '#' or '.'.",
+							piece.Name, c, x, y, z)
+					}
+					x++
+				}
+			}
+		}
+		if piece.Layers != nil || !fitsGeom(piece.Geom) {
+			defns[i].Voxels = voxels
+			continue
+		}
+		for _, voxel := range voxels {
+			defns[i].Geom[voxel[Y]][voxel[X]] = 1
+		}
+	}
+	return defns, nil
+}
+
+// Whether the rows fit in a PieceGeom.
+func fitsGeom(rows []string) bool {
+	var geom PieceGeom
+	if len(rows) > len(geom) {
+		return false
+	}
+	for _, row := range rows {
+		if len(row) > len(geom[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reads a puzzle from a puzzle file, with its pieces in their starting
+// positions.
+func LoadPuzzle(r io.Reader) (*Puzzle, error) {
+	defns, err := LoadPieceDefinitions(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewPuzzleFrom(defns...)
+}
+
+// Reads a puzzle from the puzzle file at path, or returns the Gordian Knot if
+// path is empty. The file is read as by LoadPieceDefinitionsFile.
+func LoadPuzzleFile(path string) (*Puzzle, error) {
+	if path == "" {
+		return NewPuzzle(), nil
+	}
+	defns, err := LoadPieceDefinitionsFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewPuzzleFrom(defns...)
+}
+
+// Reads piece definitions from the puzzle file at path, or returns the
+// Gordian Knot's if path is empty. Files ending in .xmpuzzle are read as
+// BurrTools files. Files ending in .yaml or .yml are refused, since only JSON
+// puzzle files are supported. The definitions are not validated; see
+// ValidateDefinitions.
+func LoadPieceDefinitionsFile(path string) ([]PieceDefinition, error) {
+	if path == "" {
+		return []PieceDefinition{BluePieceDef, OrangePieceDef, PurplePieceDef, GreenPieceDef, RedPieceDef,
+			YellowPieceDef}, nil
+	}
+	if ext := filepath.Ext(path); ext == ".yaml" || ext == ".yml" {
+		return nil, fmt.Errorf("Cannot read puzzle file %v: YAML is not supported, convert it to JSON.", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if filepath.Ext(path) == ".xmpuzzle" {
+		return LoadBurrTools(f)
+	}
+	return LoadPieceDefinitions(f)
+}
@@ -0,0 +1,111 @@
+package gknot
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadPuzzle_gordianKnot(t *testing.T) {
+	f, err := os.Open("testdata/gordianknot.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	puzzle, err := LoadPuzzle(f)
+	if err != nil {
+		t.Fatalf("LoadPuzzle returned error %v.", err)
+	}
+	if key, expectedKey := puzzle.Key(), NewPuzzle().Key(); key != expectedKey {
+		t.Fatalf("Loaded puzzle should be the Gordian Knot, state %q, actual %q.", expectedKey, key)
+	}
+	checkPiece(t, puzzle.Pieces[36], BluePieceDef.Piece().Cells)
+}
+
+func TestLoadPieceDefinitions_errors(t *testing.T) {
+	for _, test := range []struct {
+		file   string
+		errMsg string
+	}{
+		{`{"pieces": []}`, "Puzzle file has no pieces."},
+		{`{"pieces": [{"name": "A", "geom": ["#x"]}]}`, `Piece A has 'x' at (1, 0, 0); must be '#' or '.'.`},
+		{`{"pieces": [{"name": "A", "layers": [["#"], [".x"]]}]}`, `Piece A has 'x' at (1, 0, 1); must be '#' or '.'.`},
+		{`{"pieces": [{"name": "A", "geom": ["é#x"]}]}`, `Piece A has 'é' at (0, 0, 0); must be '#' or '.'.`},
+		{`{"pieces": [{"name": "A", "geom": ["#.é"]}]}`, `Piece A has 'é' at (2, 0, 0); must be '#' or '.'.`},
+		{`{"pieces": [{"name": "A", "geom": ["#"], "layers": [["#"]]}]}`, "Piece A has both geom and layers."},
+		{`{"pieces": [{"name": "A", "colour": 31}]}`, `Cannot read puzzle file: json: unknown field "colour"`},
+	} {
+		if _, err := LoadPieceDefinitions(strings.NewReader(test.file)); err == nil || err.Error() != test.errMsg {
+			t.Errorf("Loading %v should fail with '%v', actual %v.", test.file, test.errMsg, err)
+		}
+	}
+}
+
+func TestLoadPuzzleFile_yaml(t *testing.T) {
+	errMsg := "Cannot read puzzle file testdata/knot.yaml: YAML is not supported, convert it to JSON."
+	if _, err := LoadPuzzleFile("testdata/knot.yaml"); err == nil || err.Error() != errMsg {
+		t.Fatalf("Loading a YAML file should fail with '%v', actual %v.", errMsg, err)
+	}
+}
+
+func TestLoadPieceDefinitionsFile(t *testing.T) {
+	for _, test := range []struct {
+		path      string
+		numPieces int
+	}{
+		{"", 6},
+		{"testdata/gordianknot.json", 6},
+		{"testdata/cup.json", 2},
+		{"testdata/cup.xmpuzzle", 2},
+	} {
+		defns, err := LoadPieceDefinitionsFile(test.path)
+		if err != nil {
+			t.Fatalf("Expected no error for %q, actual %v.", test.path, err)
+		}
+		if len(defns) != test.numPieces {
+			t.Fatalf("Expected %v pieces in %q, actual %v.", test.numPieces, test.path, len(defns))
+		}
+	}
+	errMsg := "Cannot read puzzle file testdata/knot.yml: YAML is not supported, convert it to JSON."
+	if _, err := LoadPieceDefinitionsFile("testdata/knot.yml"); err == nil || err.Error() != errMsg {
+		t.Fatalf("Loading a YAML file should fail with '%v', actual %v.", errMsg, err)
+	}
+}
+
+func TestLoadPuzzle_invalid(t *testing.T) {
+	// A valid file, but the transform scales the piece.
+	file := `{"pieces": [{"name": "A", "color": 31, "geom": ["#"],
+		"transform": [[2, 0, 0, 0], [0, 1, 0, 0], [0, 0, 1, 0], [0, 0, 0, 1]]}]}`
+	if _, err := LoadPuzzle(strings.NewReader(file)); err == nil {
+		t.Fatalf("Loading a piece with an invalid transform should fail.")
+	} else if _, ok := err.(*InvalidTransformError); !ok {
+		t.Fatalf("Expected InvalidTransformError, actual %v.", err)
+	}
+}
+
+func TestLoadPieceDefinitions_voxels(t *testing.T) {
+	file := `{"pieces": [
+		{"name": "Flat", "geom": ["#"]},
+		{"name": "Long", "geom": ["########"]},
+		{"name": "Tall", "layers": [["#"], ["#."]]}]}`
+	defns, err := LoadPieceDefinitions(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("LoadPieceDefinitions returned error %v.", err)
+	}
+	if defns[0].Voxels != nil || defns[0].Geom[0][0] != 1 {
+		t.Fatalf("Flat piece should be loaded as Geom, actual %v.", defns[0])
+	}
+	// Pieces without a transform are placed as defined.
+	for _, defn := range defns {
+		if defn.Transform != identity {
+			t.Fatalf("%v piece without a transform should get the identity, actual %v.", defn.Name, defn.Transform)
+		}
+	}
+	if len(defns[1].Voxels) != 8 || defns[1].Voxels[7] != (Cell{7, 0, 0}) {
+		t.Fatalf("Long piece should be loaded as 8 voxels along x, actual %v.", defns[1].Voxels)
+	}
+	if expected := (Cells{{0, 0, 0}, {0, 0, 1}}); !reflect.DeepEqual(defns[2].Voxels, expected) {
+		t.Fatalf("Tall piece should be loaded as voxels %v, actual %v.", expected, defns[2].Voxels)
+	}
+}
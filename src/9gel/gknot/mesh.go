@@ -0,0 +1,338 @@
+// Triangle meshes of pieces, and writing them as STL and Wavefront OBJ files
+// for 3D printing.
+package gknot
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// How to turn cells into a mesh.
+type MeshOptions struct {
+	// The length of a cell's edges in the mesh's units, usually millimetres.
+	// 0 means 10.
+	Unit float64
+	// How far every face is moved inwards, in the mesh's units, so that
+	// printed pieces have room to slide past each other.
+	Clearance float64
+}
+
+var ErrInvalidMeshOptions = errors.New(
+	"MeshOptions.Unit and Clearance must not be negative, and Clearance must be less than half of Unit.")
+
+const defaultUnit = 10
+
+// A closed surface made of triangles.
+type Mesh struct {
+	Name     string
+	Vertices [][3]float64
+	// Indices into Vertices, counter-clockwise when seen from outside.
+	Triangles [][3]int
+}
+
+// The mesh of the piece as defined, before it is placed in the puzzle.
+func (pieceDefn PieceDefinition) Mesh(opts MeshOptions) (*Mesh, error) {
+	return meshCells(pieceDefn.Name, pieceDefn.Cells(), opts)
+}
+
+// The meshes of the puzzle's pieces in place, in ascending order of ID.
+func (puzzle *Puzzle) Meshes(opts MeshOptions) ([]*Mesh, error) {
+	var meshes []*Mesh
+	for _, piece := range puzzle.sortedPieces() {
+		mesh, err := meshCells(piece.Definition.Name, piece.Cells, opts)
+		if err != nil {
+			return nil, err
+		}
+		meshes = append(meshes, mesh)
+	}
+	return meshes, nil
+}
+
+// A rectangle of faces of cells on the surface of a solid, in the plane where
+// the axis is at, facing towards the sign of the axis. From and to are the
+// corners along the plane's two axes as in perpendicularAxes.
+type meshRect struct {
+	axis     Axis
+	sign     int
+	at       int
+	from, to [2]int
+}
+
+// The point of the rectangle's plane at u and v along its two axes.
+func (rect meshRect) point(u, v int) Cell {
+	var point Cell
+	point[rect.axis] = rect.at
+	point[perpendicularAxes[rect.axis][0]] = u
+	point[perpendicularAxes[rect.axis][1]] = v
+	return point
+}
+
+// Makes the mesh of the surface of the cells. Faces of cells in the same
+// plane are merged into rectangles, which are split into triangles meeting
+// wherever another rectangle has a corner on their edges, so that the mesh is
+// watertight. Cells that only touch along an edge or at a corner are not moved
+// apart there by the clearance.
+func meshCells(name string, cells Cells, opts MeshOptions) (*Mesh, error) {
+	unit := opts.Unit
+	if unit == 0 {
+		unit = defaultUnit
+	}
+	if unit < 0 || opts.Clearance < 0 || 2*opts.Clearance >= unit {
+		return nil, ErrInvalidMeshOptions
+	}
+	solid := make(map[Cell]bool, len(cells))
+	for _, cell := range cells {
+		solid[cell] = true
+	}
+
+	// Group the faces on the surface by their plane and the way they face.
+	type plane struct {
+		axis     Axis
+		sign, at int
+	}
+	planes := make(map[plane][][2]int)
+	for _, cell := range cells {
+		for axis := X; axis <= Z; axis++ {
+			for _, sign := range []int{-1, 1} {
+				neighbour := cell
+				neighbour[axis] += sign
+				if solid[neighbour] {
+					continue
+				}
+				at := cell[axis]
+				if sign > 0 {
+					at++
+				}
+				u, v := perpendicularAxes[axis][0], perpendicularAxes[axis][1]
+				key := plane{axis, sign, at}
+				planes[key] = append(planes[key], [2]int{cell[u], cell[v]})
+			}
+		}
+	}
+	keys := make([]plane, 0, len(planes))
+	for key := range planes {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		return a.axis < b.axis || a.axis == b.axis && (a.sign < b.sign || a.sign == b.sign && a.at < b.at)
+	})
+
+	// Merge the faces of each plane into rectangles, each as long as it can be
+	// along the first axis and then as wide as it can be along the second.
+	var rects []meshRect
+	corners := make(map[Cell]bool)
+	for _, key := range keys {
+		faces := planes[key]
+		sort.Slice(faces, func(i, j int) bool {
+			return faces[i][1] < faces[j][1] || faces[i][1] == faces[j][1] && faces[i][0] < faces[j][0]
+		})
+		free := make(map[[2]int]bool, len(faces))
+		for _, face := range faces {
+			free[face] = true
+		}
+		for _, face := range faces {
+			if !free[face] {
+				continue
+			}
+			to := [2]int{face[0] + 1, face[1] + 1}
+			for free[[2]int{to[0], face[1]}] {
+				to[0]++
+			}
+		widen:
+			for {
+				for u := face[0]; u < to[0]; u++ {
+					if !free[[2]int{u, to[1]}] {
+						break widen
+					}
+				}
+				to[1]++
+			}
+			for u := face[0]; u < to[0]; u++ {
+				for v := face[1]; v < to[1]; v++ {
+					delete(free, [2]int{u, v})
+				}
+			}
+			rect := meshRect{key.axis, key.sign, key.at, face, to}
+			rects = append(rects, rect)
+			corners[rect.point(face[0], face[1])] = true
+			corners[rect.point(to[0], face[1])] = true
+			corners[rect.point(to[0], to[1])] = true
+			corners[rect.point(face[0], to[1])] = true
+		}
+	}
+
+	mesh := &Mesh{Name: name}
+	indices := make(map[Cell]int)
+	// Adds a point of the grid, moved inwards by the clearance.
+	addPoint := func(point Cell) int {
+		if i, ok := indices[point]; ok {
+			return i
+		}
+		indices[point] = len(mesh.Vertices)
+		inwards := pointInwards(point, solid)
+		var vertex [3]float64
+		for axis := range vertex {
+			vertex[axis] = float64(point[axis])*unit + float64(inwards[axis])*opts.Clearance
+		}
+		mesh.Vertices = append(mesh.Vertices, vertex)
+		return indices[point]
+	}
+	for _, rect := range rects {
+		// The points round the rectangle, counter-clockwise seen from the
+		// positive side of its axis.
+		var round []int
+		u0, v0, u1, v1 := rect.from[0], rect.from[1], rect.to[0], rect.to[1]
+		for _, side := range [4][4]int{{u0, v0, 1, 0}, {u1, v0, 0, 1}, {u1, v1, -1, 0}, {u0, v1, 0, -1}} {
+			u, v := side[0], side[1]
+			round = append(round, addPoint(rect.point(u, v)))
+			for u, v = u+side[2], v+side[3]; u > u0 && u < u1 || v > v0 && v < v1; u, v = u+side[2], v+side[3] {
+				if point := rect.point(u, v); corners[point] {
+					round = append(round, addPoint(point))
+				}
+			}
+		}
+		if rect.sign < 0 {
+			for i, j := 0, len(round)-1; i < j; i, j = i+1, j-1 {
+				round[i], round[j] = round[j], round[i]
+			}
+		}
+		if len(round) == 4 {
+			mesh.Triangles = append(mesh.Triangles, [3]int{round[0], round[1], round[2]},
+				[3]int{round[0], round[2], round[3]})
+			continue
+		}
+		// Fan out from the centre, which is never in line with two points on
+		// the edges.
+		var centre [3]float64
+		centre[rect.axis] = float64(rect.at)*unit - float64(rect.sign)*opts.Clearance
+		centre[perpendicularAxes[rect.axis][0]] = float64(u0+u1) / 2 * unit
+		centre[perpendicularAxes[rect.axis][1]] = float64(v0+v1) / 2 * unit
+		mesh.Vertices = append(mesh.Vertices, centre)
+		for i := range round {
+			mesh.Triangles = append(mesh.Triangles, [3]int{len(mesh.Vertices) - 1, round[i], round[(i+1)%len(round)]})
+		}
+	}
+	return mesh, nil
+}
+
+// The way a point of the grid moves, along each axis, when the surface of the
+// solid moves inwards: towards the side of the point that has more of the
+// solid's cells around it.
+func pointInwards(point Cell, solid map[Cell]bool) (inwards [3]int) {
+	for corner := 0; corner < 8; corner++ {
+		cell := Cell{point[X] - 1 + corner&1, point[Y] - 1 + corner>>1&1, point[Z] - 1 + corner>>2}
+		if !solid[cell] {
+			continue
+		}
+		for axis := X; axis <= Z; axis++ {
+			if cell[axis] < point[axis] {
+				inwards[axis]--
+			} else {
+				inwards[axis]++
+			}
+		}
+	}
+	for axis, v := range inwards {
+		switch {
+		case v > 0:
+			inwards[axis] = 1
+		case v < 0:
+			inwards[axis] = -1
+		}
+	}
+	return inwards
+}
+
+// The unit normal of the triangle, pointing out of the mesh.
+func (mesh *Mesh) normal(triangle [3]int) [3]float64 {
+	a, b, c := mesh.Vertices[triangle[0]], mesh.Vertices[triangle[1]], mesh.Vertices[triangle[2]]
+	var ab, ac [3]float64
+	for i := range ab {
+		ab[i], ac[i] = b[i]-a[i], c[i]-a[i]
+	}
+	n := [3]float64{ab[1]*ac[2] - ab[2]*ac[1], ab[2]*ac[0] - ab[0]*ac[2], ab[0]*ac[1] - ab[1]*ac[0]}
+	length := math.Sqrt(n[0]*n[0] + n[1]*n[1] + n[2]*n[2])
+	for i := range n {
+		n[i] /= length
+	}
+	return n
+}
+
+// Writes the meshes to a binary STL file as one solid.
+func WriteSTL(w io.Writer, meshes ...*Mesh) error {
+	bw := bufio.NewWriter(w)
+	var header [80]byte
+	copy(header[:], "gknot")
+	if len(meshes) == 1 {
+		copy(header[:], meshes[0].Name)
+	}
+	bw.Write(header[:])
+	numTriangles := 0
+	for _, mesh := range meshes {
+		numTriangles += len(mesh.Triangles)
+	}
+	binary.Write(bw, binary.LittleEndian, uint32(numTriangles))
+	for _, mesh := range meshes {
+		for _, triangle := range mesh.Triangles {
+			var facet [12]float32
+			normal := mesh.normal(triangle)
+			for i := range normal {
+				facet[i] = float32(normal[i])
+			}
+			for i, vertex := range triangle {
+				for j, v := range mesh.Vertices[vertex] {
+					facet[3+3*i+j] = float32(v)
+				}
+			}
+			binary.Write(bw, binary.LittleEndian, facet)
+			// No attributes.
+			binary.Write(bw, binary.LittleEndian, uint16(0))
+		}
+	}
+	return bw.Flush()
+}
+
+// Writes the meshes to an ASCII STL file, each as a solid of its own.
+func WriteASCIISTL(w io.Writer, meshes ...*Mesh) error {
+	bw := bufio.NewWriter(w)
+	for _, mesh := range meshes {
+		fmt.Fprintf(bw, "solid %v\n", mesh.Name)
+		for _, triangle := range mesh.Triangles {
+			normal := mesh.normal(triangle)
+			fmt.Fprintf(bw, "  facet normal %g %g %g\n", normal[0], normal[1], normal[2])
+			bw.WriteString("    outer loop\n")
+			for _, vertex := range triangle {
+				v := mesh.Vertices[vertex]
+				fmt.Fprintf(bw, "      vertex %g %g %g\n", v[0], v[1], v[2])
+			}
+			bw.WriteString("    endloop\n")
+			bw.WriteString("  endfacet\n")
+		}
+		fmt.Fprintf(bw, "endsolid %v\n", mesh.Name)
+	}
+	return bw.Flush()
+}
+
+// Writes the meshes to a Wavefront OBJ file, each as an object of its own.
+func WriteOBJ(w io.Writer, meshes ...*Mesh) error {
+	bw := bufio.NewWriter(w)
+	// Vertices are numbered from 1 across the file.
+	first := 1
+	for _, mesh := range meshes {
+		fmt.Fprintf(bw, "o %v\n", mesh.Name)
+		for _, v := range mesh.Vertices {
+			fmt.Fprintf(bw, "v %g %g %g\n", v[0], v[1], v[2])
+		}
+		for _, triangle := range mesh.Triangles {
+			fmt.Fprintf(bw, "f %v %v %v\n", first+triangle[0], first+triangle[1], first+triangle[2])
+		}
+		first += len(mesh.Vertices)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,159 @@
+// Writes meshes of the pieces for 3D printing.
+package main
+
+import (
+	"9gel/gknot"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+)
+
+var (
+	puzzleFile = flag.String("puzzle", "", "Puzzle file to write the pieces of. Defaults to the Gordian Knot.")
+	format     = flag.String("format", "stl", "File format: stl (binary STL), ascii_stl or obj.")
+	unit       = flag.Float64("unit", 10, "Length of a cell's edges, usually in millimetres.")
+	clearance  = flag.Float64("clearance", 0,
+		"How far to move every face inwards, in the same units as -unit, so that printed pieces slide.")
+	out   = flag.String("out", ".", "Directory to write a file per piece to, or the file to write with -whole.")
+	whole = flag.Bool("whole", false, "Write the whole puzzle, with the pieces in place, to one file.")
+	step  = flag.Int("step", 0,
+		"With -whole, write the puzzle after this many steps of the fewest moves that take it apart, "+
+			"numbered as the replay command numbers them. A removal is written before the pieces are "+
+			"taken off, and the steps after it only have the group they are made on. 0 writes the "+
+			"starting state.")
+	maxStates = flag.Int("max_states", 0, "With -step, maximum number of states to visit. 0 means no limit.")
+	maxDepth  = flag.Int("max_depth", 0,
+		"With -step, maximum number of moves, not counting removals, to free each group. 0 means no limit.")
+	timeout       = flag.Duration("timeout", 0, "With -step, maximum time to search for. 0 means no limit.")
+	rotations     = flag.Bool("rotations", false, "With -step, also try quarter turns of pieces and groups.")
+	maxSubsetSize = flag.Int("max_subset_size", 0,
+		"With -step, also slide groups of 2 up to this many pieces together as one move. 0 means one piece "+
+			"at a time.")
+	workers = flag.Int("workers", 0, "With -step, number of goroutines to search on. 0 means GOMAXPROCS.")
+)
+
+var writers = map[string]struct {
+	write     func(io.Writer, ...*gknot.Mesh) error
+	extension string
+}{
+	"stl":       {gknot.WriteSTL, ".stl"},
+	"ascii_stl": {gknot.WriteASCIISTL, ".stl"},
+	"obj":       {gknot.WriteOBJ, ".obj"},
+}
+
+func main() {
+	flag.Parse()
+	writer, ok := writers[*format]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown format %q.\n", *format)
+		os.Exit(2)
+	}
+	puzzle, err := gknot.LoadPuzzleFile(*puzzleFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	opts := gknot.MeshOptions{Unit: *unit, Clearance: *clearance}
+	if *whole {
+		err = writeWhole(puzzle, opts, writer.write)
+	} else {
+		err = writePieces(puzzle, opts, writer.write, writer.extension)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Writes the puzzle after the steps given by the flag to one file.
+func writeWhole(puzzle *gknot.Puzzle, opts gknot.MeshOptions, write func(io.Writer, ...*gknot.Mesh) error) error {
+	puzzle, err := afterSteps(puzzle)
+	if err != nil {
+		return err
+	}
+	meshes, err := puzzle.Meshes(opts)
+	if err != nil {
+		return err
+	}
+	return writeFile(*out, write, meshes...)
+}
+
+// Returns the puzzle after the steps given by the flag of the fewest moves
+// that take it apart, searched for within the limits given by the flags. A
+// removal gives the pieces before they are taken off, as the replay command
+// shows them.
+func afterSteps(puzzle *gknot.Puzzle) (*gknot.Puzzle, error) {
+	if *step < 0 {
+		return nil, fmt.Errorf("Step %v is negative.", *step)
+	}
+	if *step == 0 {
+		return puzzle, nil
+	}
+	// Interrupting stops the search.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	solution, err := puzzle.SolveContext(ctx, gknot.SolveOptions{
+		Goal:          gknot.Disassemble,
+		MaxStates:     *maxStates,
+		MaxDepth:      *maxDepth,
+		Timeout:       *timeout,
+		Rotations:     *rotations,
+		MaxSubsetSize: *maxSubsetSize,
+		Workers:       *workers})
+	if err != nil {
+		return nil, err
+	}
+	if solution.Reason != gknot.Solved {
+		return nil, fmt.Errorf("No solution to take steps of. Stopped: %v after visiting %v states.",
+			solution.Reason, solution.Stats.StatesVisited)
+	}
+	steps := solution.Steps(puzzle)
+	if *step > len(steps) {
+		return nil, fmt.Errorf("Step %v is past the last step, %v.", *step, len(steps))
+	}
+	last := steps[*step-1]
+	if last.Move.Removed {
+		return last.From, nil
+	}
+	return last.To, nil
+}
+
+// Writes each piece as defined to a file named after it, in order of ID.
+func writePieces(puzzle *gknot.Puzzle, opts gknot.MeshOptions, write func(io.Writer, ...*gknot.Mesh) error,
+	extension string) error {
+	pieceIDs := make([]int, 0, len(puzzle.Pieces))
+	for pieceID := range puzzle.Pieces {
+		pieceIDs = append(pieceIDs, int(pieceID))
+	}
+	sort.Ints(pieceIDs)
+	for _, pieceID := range pieceIDs {
+		piece := puzzle.Pieces[uint8(pieceID)]
+		mesh, err := piece.Definition.Mesh(opts)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(*out, piece.Definition.Name+extension)
+		if err := writeFile(path, write, mesh); err != nil {
+			return err
+		}
+		fmt.Println("Wrote", path)
+	}
+	return nil
+}
+
+func writeFile(path string, write func(io.Writer, ...*gknot.Mesh) error, meshes ...*gknot.Mesh) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f, meshes...); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,145 @@
+package gknot
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// Checks that every edge of the mesh's triangles is met by as many triangles
+// going the other way, so that the mesh is closed, and returns the volume the
+// mesh encloses. Edges are met more than once where cells of a piece only
+// touch along an edge, as in the Blue piece.
+func checkMesh(t *testing.T, mesh *Mesh) float64 {
+	edges := make(map[[2]int]int)
+	volume := 0.0
+	for _, triangle := range mesh.Triangles {
+		for i := range triangle {
+			edges[[2]int{triangle[i], triangle[(i+1)%3]}]++
+		}
+		a, b, c := mesh.Vertices[triangle[0]], mesh.Vertices[triangle[1]], mesh.Vertices[triangle[2]]
+		volume += (a[0]*(b[1]*c[2]-b[2]*c[1]) - a[1]*(b[0]*c[2]-b[2]*c[0]) + a[2]*(b[0]*c[1]-b[1]*c[0])) / 6
+	}
+	for edge, count := range edges {
+		if edges[[2]int{edge[1], edge[0]}] != count {
+			t.Fatalf("Mesh %v is not watertight at edge %v.", mesh.Name, edge)
+		}
+	}
+	return volume
+}
+
+func TestMesh_cube(t *testing.T) {
+	mesh, err := cubePieceDef.Mesh(MeshOptions{Clearance: 0.5})
+	if err != nil {
+		t.Fatalf("Mesh returned error %v.", err)
+	}
+	if len(mesh.Vertices) != 8 || len(mesh.Triangles) != 12 {
+		t.Fatalf("Expected 8 vertices and 12 triangles, actual %v and %v.", len(mesh.Vertices), len(mesh.Triangles))
+	}
+	if volume := checkMesh(t, mesh); math.Abs(volume-9*9*9) > 1e-9 {
+		t.Fatalf("Expected volume 729, actual %v.", volume)
+	}
+}
+
+func TestMesh_mergedFaces(t *testing.T) {
+	// A bar of 3 cells has 6 rectangles, the long ones split where the end
+	// faces meet them, 2 triangles each.
+	bar := PieceDefinition{Name: "Bar", Voxels: Cells{{0, 0, 0}, {1, 0, 0}, {2, 0, 0}}}
+	mesh, err := bar.Mesh(MeshOptions{Unit: 1})
+	if err != nil {
+		t.Fatalf("Mesh returned error %v.", err)
+	}
+	if len(mesh.Triangles) != 12 {
+		t.Fatalf("Expected 12 triangles, actual %v.", len(mesh.Triangles))
+	}
+	if volume := checkMesh(t, mesh); math.Abs(volume-3) > 1e-9 {
+		t.Fatalf("Expected volume 3, actual %v.", volume)
+	}
+}
+
+func TestMeshes_watertight(t *testing.T) {
+	cup, err := LoadPuzzleFile("testdata/cup.json")
+	if err != nil {
+		t.Fatalf("LoadPuzzleFile returned error %v.", err)
+	}
+	for _, puzzle := range []*Puzzle{NewPuzzle(), cup} {
+		for _, opts := range []MeshOptions{{}, {Unit: 8, Clearance: 0.2}} {
+			meshes, err := puzzle.Meshes(opts)
+			if err != nil {
+				t.Fatalf("Meshes returned error %v.", err)
+			}
+			for i, piece := range puzzle.sortedPieces() {
+				volume := checkMesh(t, meshes[i])
+				// Without clearance, the volume is that of the cells.
+				cellVolume := float64(len(piece.Cells)) * 1000
+				if opts.Clearance == 0 && math.Abs(volume-cellVolume) > 1e-6 {
+					t.Fatalf("%v should have volume %v, actual %v.", meshes[i].Name, cellVolume, volume)
+				}
+				if opts.Clearance > 0 && volume >= float64(len(piece.Cells))*8*8*8 {
+					t.Fatalf("%v should shrink with clearance, actual volume %v.", meshes[i].Name, volume)
+				}
+			}
+		}
+	}
+}
+
+func TestMesh_errors(t *testing.T) {
+	for _, opts := range []MeshOptions{{Unit: -1}, {Clearance: -1}, {Unit: 2, Clearance: 1}, {Clearance: 5}} {
+		if _, err := cubePieceDef.Mesh(opts); err != ErrInvalidMeshOptions {
+			t.Fatalf("Expected ErrInvalidMeshOptions for %v, actual %v.", opts, err)
+		}
+	}
+}
+
+func TestWriteSTL(t *testing.T) {
+	mesh, err := cubePieceDef.Mesh(MeshOptions{})
+	if err != nil {
+		t.Fatalf("Mesh returned error %v.", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteSTL(&buf, mesh, mesh); err != nil {
+		t.Fatalf("WriteSTL returned error %v.", err)
+	}
+	// An 80 byte header, the number of triangles and 50 bytes per triangle.
+	if buf.Len() != 84+50*24 {
+		t.Fatalf("Expected %v bytes, actual %v.", 84+50*24, buf.Len())
+	}
+	if numTriangles := buf.Bytes()[80]; numTriangles != 24 {
+		t.Fatalf("Expected 24 triangles, actual %v.", numTriangles)
+	}
+
+	buf.Reset()
+	if err := WriteASCIISTL(&buf, mesh); err != nil {
+		t.Fatalf("WriteASCIISTL returned error %v.", err)
+	}
+	stl := buf.String()
+	if !strings.HasPrefix(stl, "solid Cube\n") || !strings.HasSuffix(stl, "endsolid Cube\n") ||
+		strings.Count(stl, "facet normal") != 12 || strings.Count(stl, "vertex") != 36 {
+		t.Fatalf("Expected a solid of 12 facets, actual:\n%v", stl)
+	}
+}
+
+func TestWriteOBJ(t *testing.T) {
+	mesh, err := cubePieceDef.Mesh(MeshOptions{})
+	if err != nil {
+		t.Fatalf("Mesh returned error %v.", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteOBJ(&buf, mesh, mesh); err != nil {
+		t.Fatalf("WriteOBJ returned error %v.", err)
+	}
+	obj := buf.String()
+	if strings.Count(obj, "o Cube\n") != 2 || strings.Count(obj, "\nv ") != 16 || strings.Count(obj, "\nf ") != 24 {
+		t.Fatalf("Expected 2 objects of 8 vertices and 12 faces, actual:\n%v", obj)
+	}
+	// The second object's faces use its own vertices, 9 to 16.
+	second := obj[strings.LastIndex(obj, "o Cube"):]
+	for _, line := range strings.Split(second, "\n") {
+		var a, b, c int
+		if n, _ := fmt.Sscanf(line, "f %d %d %d", &a, &b, &c); n == 3 && (a < 9 || b < 9 || c < 9 || a > 16 || b > 16 || c > 16) {
+			t.Fatalf("Second object should use vertices 9 to 16, actual %q.", line)
+		}
+	}
+}
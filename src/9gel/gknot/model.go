@@ -32,9 +32,11 @@
 package gknot
 
 import (
+	"encoding/binary"
 	"fmt"
 	"hash/fnv"
 	"sort"
+	"strings"
 )
 
 // A piece is defined by 5x7 matrix PieceGeom since each piece is 5 cells by
@@ -46,6 +48,10 @@ import (
 // values are invalid. The piece is also defined by a transformation matrix
 // to transform it from the x-y plane to the starting position in the puzzle
 // in 3 space.
+//
+// Pieces of other shapes and sizes, such as the pieces of burr puzzles, are
+// defined by Voxels instead: the coordinates of their solid cells, in the
+// piece's own coordinate system.
 type PieceGeom [5][7]uint8
 type TransformMatrix [4][4]int
 type PieceDefinition struct {
@@ -54,15 +60,24 @@ type PieceDefinition struct {
 	EscColor  uint8
 	Geom      PieceGeom
 	Transform TransformMatrix
+	// If not nil, the solid cells of the piece, used instead of Geom.
+	Voxels Cells
 }
 
 // Piece represents a piece as a set of coordinates of its solid cells.
-// Cells[0] is always the (0, 0) cell in the PieceDefinition.
+// Cells are in the order PieceDefinition.Cells gives them, which moves keep,
+// so that the state ID can use the first and last of them.
 type Cell [3]int
 type Cells []Cell
 type Piece struct {
 	Definition *PieceDefinition
+	// Places the piece in 3-space: Cells are the cells of the definition's
+	// geometry transformed by it.
+	Transform TransformMatrix
 	Cells
+	// The rotations, other than the identity, that turn the cells of the
+	// definition onto themselves, shared by the piece's moves.
+	symmetries []TransformMatrix
 }
 
 type Pieces []*Piece
@@ -85,16 +100,17 @@ type Mutation struct {
 
 // Error for when two pieces occupy the same cell.
 type OverlapError struct {
-	pieces Pieces
-	cell   *Cell
+	// The piece being added, followed by the piece already occupying the cell.
+	Pieces Pieces
+	Cell   Cell
 }
 
 func (e *OverlapError) Error() string {
-	pieceNames := make([]string, len(e.pieces))
-	for i, piece := range e.pieces {
+	pieceNames := make([]string, len(e.Pieces))
+	for i, piece := range e.Pieces {
 		pieceNames[i] = piece.Definition.Name
 	}
-	return fmt.Sprintf("Overlapping pieces %v at %v.", pieceNames, *e.cell)
+	return fmt.Sprintf("Overlapping pieces %v at %v.", pieceNames, e.Cell)
 }
 
 // Error for when two pieces have the same name.
@@ -115,6 +131,57 @@ func (e *SameEscColorError) Error() string {
 	return fmt.Sprintf("More than one piece with the same ANSI Esc color %v.", e.EscColor)
 }
 
+// Error for when a piece's geometry has a value other than 0 or 1.
+type InvalidGeomError struct {
+	PieceName string
+	// Coordinates of the invalid value, i.e. Geom[Y][X].
+	X, Y  int
+	Value uint8
+}
+
+func (e *InvalidGeomError) Error() string {
+	return fmt.Sprintf("Piece %v has invalid value %v at (%v, %v); must be 0 or 1.", e.PieceName, e.Value, e.X, e.Y)
+}
+
+// Error for when a piece's voxels have the same cell more than once.
+type DuplicateVoxelError struct {
+	PieceName string
+	Cell      Cell
+}
+
+func (e *DuplicateVoxelError) Error() string {
+	return fmt.Sprintf("Piece %v has more than one voxel at %v.", e.PieceName, e.Cell)
+}
+
+// Error for when a piece has no solid cells.
+type EmptyPieceError struct {
+	PieceName string
+}
+
+func (e *EmptyPieceError) Error() string {
+	return fmt.Sprintf("Piece %v has no solid cells.", e.PieceName)
+}
+
+// Error for when a piece's transform is not a rigid motion, i.e. a rotation by
+// multiples of 90 degrees followed by a translation.
+type InvalidTransformError struct {
+	PieceName string
+	Transform TransformMatrix
+}
+
+func (e *InvalidTransformError) Error() string {
+	return fmt.Sprintf("Piece %v has transform %v that is not a rigid motion.", e.PieceName, e.Transform)
+}
+
+// Error for when a mutation refers to a piece not in the puzzle.
+type UnknownPieceError struct {
+	PieceID uint8
+}
+
+func (e *UnknownPieceError) Error() string {
+	return fmt.Sprintf("No piece with ID %v in the puzzle.", e.PieceID)
+}
+
 var (
 	BluePieceDef = PieceDefinition{
 		"Blue",
@@ -132,7 +199,8 @@ var (
 			{1, 0, 0, 0},
 			{0, 0, -1, 2},
 			{0, 1, 0, 1},
-			{0, 0, 0, 1}}}
+			{0, 0, 0, 1}},
+		nil}
 	OrangePieceDef = PieceDefinition{
 		"Orange",
 		35, // Magenta.
@@ -149,7 +217,8 @@ var (
 			{1, 0, 0, 0},
 			{0, 0, -1, 4},
 			{0, 1, 0, 1},
-			{0, 0, 0, 1}}}
+			{0, 0, 0, 1}},
+		nil}
 	PurplePieceDef = PieceDefinition{
 		"Purple",
 		34, // Blue/Purple in Terminal.app.
@@ -166,7 +235,8 @@ var (
 			{0, 0, -1, 2},
 			{0, 1, 0, 1},
 			{1, 0, 0, 0},
-			{0, 0, 0, 1}}}
+			{0, 0, 0, 1}},
+		nil}
 	GreenPieceDef = PieceDefinition{
 		"Green",
 		32,
@@ -183,7 +253,8 @@ var (
 			{0, 0, -1, 4},
 			{0, 1, 0, 1},
 			{1, 0, 0, 0},
-			{0, 0, 0, 1}}}
+			{0, 0, 0, 1}},
+		nil}
 	RedPieceDef = PieceDefinition{
 		"Red",
 		31,
@@ -201,7 +272,8 @@ var (
 			{0, 1, 0, 1},
 			{-1, 0, 0, 6},
 			{0, 0, 1, 2},
-			{0, 0, 0, 1}}}
+			{0, 0, 0, 1}},
+		nil}
 	YellowPieceDef = PieceDefinition{
 		"Yellow",
 		33,
@@ -219,10 +291,18 @@ var (
 			{0, 1, 0, 1},
 			{-1, 0, 0, 6},
 			{0, 0, 1, 4},
-			{0, 0, 0, 1}}}
+			{0, 0, 0, 1}},
+		nil}
 )
 
-func (pieceDefn PieceDefinition) Piece() *Piece {
+// The solid cells of the piece before it is transformed: a copy of Voxels, or
+// else the cells of Geom laid flat on the x-y plane.
+func (pieceDefn PieceDefinition) Cells() Cells {
+	if pieceDefn.Voxels != nil {
+		cells := make(Cells, len(pieceDefn.Voxels))
+		copy(cells, pieceDefn.Voxels)
+		return cells
+	}
 	// Build list of cells.
 	numCells := 0
 	for _, row := range pieceDefn.Geom {
@@ -236,11 +316,16 @@ func (pieceDefn PieceDefinition) Piece() *Piece {
 			}
 		}
 	}
+	return cells
+}
+
+func (pieceDefn PieceDefinition) Piece() *Piece {
+	cells := pieceDefn.Cells()
 
 	// Transform the cells.
 	cells.transform(&pieceDefn.Transform)
 
-	return &Piece{&pieceDefn, cells}
+	return &Piece{&pieceDefn, pieceDefn.Transform, cells, pieceDefn.symmetries()}
 }
 
 func (cells Cells) transform(transform *TransformMatrix) {
@@ -249,6 +334,19 @@ func (cells Cells) transform(transform *TransformMatrix) {
 	}
 }
 
+// Returns the transform that applies other first, then transform.
+func (transform TransformMatrix) multiply(other *TransformMatrix) TransformMatrix {
+	var product TransformMatrix
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			for k := 0; k < 4; k++ {
+				product[i][j] += transform[i][k] * other[k][j]
+			}
+		}
+	}
+	return product
+}
+
 func (cell Cell) transform(transform *TransformMatrix) Cell {
 	newCell := Cell{0, 0, 0}
 	for i := 0; i < 3; i++ {
@@ -260,39 +358,158 @@ func (cell Cell) transform(transform *TransformMatrix) Cell {
 	return newCell
 }
 
-func (puzzle *Puzzle) add(pieces ...*Piece) {
-	nameSet := make(map[string]bool)
-	escColorSet := make(map[uint8]bool)
+// Adds the pieces to the puzzle. Returns an OverlapError, SameNameError or
+// SameEscColorError if the pieces cannot be added, either because of each
+// other or of the pieces already in the puzzle, in which case the puzzle may
+// have been partly modified.
+func (puzzle *Puzzle) tryAdd(pieces ...*Piece) error {
+	nameSet := make(map[string]bool, len(puzzle.Pieces)+len(pieces))
+	for _, piece := range puzzle.Pieces {
+		nameSet[piece.Definition.Name] = true
+	}
 	for _, piece := range pieces {
+		if _, ok := nameSet[piece.Definition.Name]; ok {
+			return &SameNameError{piece.Definition.Name}
+		}
+		if _, ok := puzzle.Pieces[piece.Definition.EscColor]; ok {
+			return &SameEscColorError{piece.Definition.EscColor}
+		}
 		for _, cell := range piece.Cells {
 			if existPiece, ok := puzzle.CellMap[cell]; ok {
-				// Panic because the default puzzle should not have overlapping cells.
-				panic(&OverlapError{[]*Piece{piece, existPiece}, &cell})
-			}
-			if _, ok := nameSet[piece.Definition.Name]; ok {
-				// Panic because the default puzzle should not have pieces with the
-				// same names.
-				panic(&SameNameError{piece.Definition.Name})
-			}
-			if _, ok := escColorSet[piece.Definition.EscColor]; ok {
-				// Panic because the default puzzle should not have pieces with the
-				// same esc color.
-				panic(&SameEscColorError{piece.Definition.EscColor})
+				return &OverlapError{[]*Piece{piece, existPiece}, cell}
 			}
 			puzzle.CellMap[cell] = piece
 		}
+		nameSet[piece.Definition.Name] = true
 		puzzle.Pieces[piece.Definition.EscColor] = piece
 	}
+	return nil
+}
+
+// Checks that the definition's geometry has only 0 and 1 values, or that its
+// voxels are distinct, that it has at least one solid cell, and that its
+// transform is a rigid motion. Returns an InvalidGeomError,
+// DuplicateVoxelError, EmptyPieceError or InvalidTransformError otherwise.
+func (pieceDefn PieceDefinition) Validate() error {
+	numCells := len(pieceDefn.Voxels)
+	if pieceDefn.Voxels != nil {
+		voxelSet := make(map[Cell]bool, numCells)
+		for _, voxel := range pieceDefn.Voxels {
+			if voxelSet[voxel] {
+				return &DuplicateVoxelError{pieceDefn.Name, voxel}
+			}
+			voxelSet[voxel] = true
+		}
+	} else {
+		for y, row := range pieceDefn.Geom {
+			for x, v := range row {
+				if v > 1 {
+					return &InvalidGeomError{pieceDefn.Name, x, y, v}
+				}
+				numCells += int(v)
+			}
+		}
+	}
+	if numCells == 0 {
+		return &EmptyPieceError{pieceDefn.Name}
+	}
+	if !pieceDefn.Transform.isRigidMotion() {
+		return &InvalidTransformError{pieceDefn.Name, pieceDefn.Transform}
+	}
+	return nil
+}
+
+// Whether the transform rotates by multiples of 90 degrees, without
+// reflecting, then translates. Its rotation part then has exactly one 1 or -1
+// in each row and column, and a determinant of 1.
+func (transform TransformMatrix) isRigidMotion() bool {
+	if transform[3] != [4]int{0, 0, 0, 1} {
+		return false
+	}
+	var columnUsed [3]bool
+	for i := 0; i < 3; i++ {
+		nonZero := 0
+		for j := 0; j < 3; j++ {
+			switch transform[i][j] {
+			case 0:
+			case 1, -1:
+				if columnUsed[j] {
+					return false
+				}
+				columnUsed[j] = true
+				nonZero++
+			default:
+				return false
+			}
+		}
+		if nonZero != 1 {
+			return false
+		}
+	}
+	det := 0
+	for j := 0; j < 3; j++ {
+		det += transform[0][j] * (transform[1][(j+1)%3]*transform[2][(j+2)%3] -
+			transform[1][(j+2)%3]*transform[2][(j+1)%3])
+	}
+	return det == 1
+}
+
+// Checks that each definition is valid and that no two have the same name or
+// esc color. Returns the first error found.
+func ValidateDefinitions(defns ...PieceDefinition) error {
+	nameSet := make(map[string]bool, len(defns))
+	escColorSet := make(map[uint8]bool, len(defns))
+	for _, defn := range defns {
+		if err := defn.Validate(); err != nil {
+			return err
+		}
+		if _, ok := nameSet[defn.Name]; ok {
+			return &SameNameError{defn.Name}
+		}
+		if _, ok := escColorSet[defn.EscColor]; ok {
+			return &SameEscColorError{defn.EscColor}
+		}
+		nameSet[defn.Name] = true
+		escColorSet[defn.EscColor] = true
+	}
+	return nil
+}
+
+// Adds the pieces to the puzzle. Panics with the error from tryAdd if the
+// pieces cannot be added, for use where the pieces are known to fit.
+func (puzzle *Puzzle) add(pieces ...*Piece) {
+	if err := puzzle.tryAdd(pieces...); err != nil {
+		panic(err)
+	}
+}
+
+// Returns a puzzle made of the pieces defined, or the error from
+// ValidateDefinitions or an OverlapError if they do not make a valid puzzle.
+func NewPuzzleFrom(defns ...PieceDefinition) (*Puzzle, error) {
+	if err := ValidateDefinitions(defns...); err != nil {
+		return nil, err
+	}
+	puzzle := &Puzzle{make(map[uint8]*Piece, len(defns)), make(CellMap)}
+	for _, defn := range defns {
+		if err := puzzle.tryAdd(defn.Piece()); err != nil {
+			return nil, err
+		}
+	}
+	return puzzle, nil
 }
 
 func NewPuzzle() *Puzzle {
-	puzzle := &Puzzle{make(map[uint8]*Piece, 6), make(CellMap)}
-	puzzle.add(BluePieceDef.Piece(),
-		OrangePieceDef.Piece(),
-		PurplePieceDef.Piece(),
-		GreenPieceDef.Piece(),
-		RedPieceDef.Piece(),
-		YellowPieceDef.Piece())
+	puzzle, err := NewPuzzleFrom(BluePieceDef,
+		OrangePieceDef,
+		PurplePieceDef,
+		GreenPieceDef,
+		RedPieceDef,
+		YellowPieceDef)
+	if err != nil {
+		// Panic because the default puzzle should be valid and not have
+		// overlapping cells.
+		panic(err)
+	}
 	return puzzle
 }
 
@@ -303,6 +520,18 @@ type ByEscColor struct { Pieces }
 
 func (p ByEscColor) Less(i, j int) bool { return p.Pieces[i].Definition.EscColor < p.Pieces[j].Definition.EscColor }
 
+// The pieces of the puzzle in ascending EscColor order.
+func (puzzle Puzzle) sortedPieces() Pieces {
+	pieces := make(Pieces, 0, len(puzzle.Pieces))
+	for _, piece := range puzzle.Pieces {
+		pieces = append(pieces, piece)
+	}
+	sort.Sort(ByEscColor{pieces})
+	return pieces
+}
+
+// A short ID of a puzzle's state for display. Distinct states may have the same
+// ID; use StateKey to tell states apart.
 type StateID string
 
 // The state ID of a puzzle is calculated from it's pieces' configuration. The puzzle is
@@ -310,9 +539,19 @@ type StateID string
 func (puzzle Puzzle) StateID() StateID {
 	// Find the min x, min y and min z and move the puzzle to have min x, min y and min z == 0.
 	// Calculate ID for each piece and concatenate.
-	minX := 30
-	minY := minX
-	minZ := minY
+	minX, minY, minZ := puzzle.minCoords()
+	hash := fnv.New32()
+	for _, piece := range puzzle.sortedPieces() {
+		hash.Write(piece.stateID(-minX, -minY, -minZ))
+	}
+	return StateID(fmt.Sprintf("%X", hash.Sum32()))
+}
+
+// The minimum x, y and z of the puzzle's cells.
+func (puzzle Puzzle) minCoords() (minX, minY, minZ int) {
+	minX = int(^uint(0) >> 1)
+	minY = minX
+	minZ = minY
 	for cell := range puzzle.CellMap {
 		if minX > cell[0] {
 			minX = cell[0]
@@ -324,16 +563,98 @@ func (puzzle Puzzle) StateID() StateID {
 			minZ = cell[2]
 		}
 	}
-	pieces := make(Pieces, 0, len(puzzle.Pieces))
-	for _, piece := range puzzle.Pieces {
-		pieces = append(pieces, piece)
+	return
+}
+
+// An exact encoding of a puzzle's state, used to tell states apart. Two
+// puzzles have the same key if and only if they have the same pieces
+// occupying the same cells, relative to each other. A piece turned onto
+// itself, such as a bar turned about its length, is in the same state.
+type StateKey string
+
+// The state key of a puzzle is made of each piece's ID, orientation and
+// minimum x, y and z, in ascending ID order. Of the orientations that put the
+// piece's cells in the same place, the one with the least number is used.
+// Like for the state ID, the puzzle is normalized to having 0 minimum x, y
+// and z.
+func (puzzle Puzzle) Key() StateKey {
+	minX, minY, minZ := puzzle.minCoords()
+	key := make([]byte, 0, len(puzzle.Pieces)*(3+3*binary.MaxVarintLen64))
+	for _, piece := range puzzle.sortedPieces() {
+		pieceMin := piece.Cells.min()
+		key = append(key, piece.Definition.EscColor)
+		key = binary.LittleEndian.AppendUint16(key, piece.orientation())
+		key = binary.AppendVarint(key, int64(pieceMin[0]-minX))
+		key = binary.AppendVarint(key, int64(pieceMin[1]-minY))
+		key = binary.AppendVarint(key, int64(pieceMin[2]-minZ))
 	}
-	sort.Sort(ByEscColor{pieces})
-	hash := fnv.New32()
-	for _, piece := range pieces {
-		hash.Write(piece.stateID(-minX, -minY, -minZ))
+	return StateKey(key)
+}
+
+// The least number of the orientations of the piece's transform combined with
+// each rotation that turns the piece's definition onto itself.
+func (piece Piece) orientation() uint16 {
+	orientation := piece.Transform.orientation()
+	for _, symmetry := range piece.symmetries {
+		if other := piece.Transform.multiply(&symmetry).orientation(); other < orientation {
+			orientation = other
+		}
 	}
-	return StateID(fmt.Sprintf("%X", hash.Sum32()))
+	return orientation
+}
+
+// Packs the rotation part of a rigid motion, whose entries are -1, 0 or 1,
+// into a number in base 3.
+func (transform TransformMatrix) orientation() uint16 {
+	var packed uint16
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			packed = packed*3 + uint16(transform[i][j]+1)
+		}
+	}
+	return packed
+}
+
+// Returns the rotations, other than the identity, that turn the cells of the
+// definition onto themselves once moved back by a translation.
+func (pieceDefn PieceDefinition) symmetries() []TransformMatrix {
+	cells := pieceDefn.Cells()
+	shape := cells.shape()
+	var symmetries []TransformMatrix
+	for _, rotation := range burrRotations[1:] {
+		rotated := make(Cells, len(cells))
+		copy(rotated, cells)
+		rotated.transform(&rotation)
+		if rotated.shape() == shape {
+			symmetries = append(symmetries, rotation)
+		}
+	}
+	return symmetries
+}
+
+// The minimum x, y and z of the cells.
+func (cells Cells) min() Cell {
+	min := cells[0]
+	for _, cell := range cells[1:] {
+		for i, v := range cell {
+			if v < min[i] {
+				min[i] = v
+			}
+		}
+	}
+	return min
+}
+
+// The cells moved to have 0 minimum x, y and z, sorted, as a string that is
+// the same for cells of the same shape.
+func (cells Cells) shape() string {
+	min := cells.min()
+	moved := make([]string, len(cells))
+	for i, cell := range cells {
+		moved[i] = fmt.Sprint(Cell{cell[0] - min[0], cell[1] - min[1], cell[2] - min[2]})
+	}
+	sort.Strings(moved)
+	return strings.Join(moved, "")
 }
 
 // The state ID of a piece is calculated from it's position and orientation in 3-space.
@@ -354,20 +675,61 @@ func (piece Piece) stateID(shiftX, shiftY, shiftZ int) []byte {
 // Mutates the puzzle and returns a new puzzle with copied pieces.
 // The pieces must not overlap, otherwise panic with OverlapError.
 func (puzzle Puzzle) Mutate(mutations ...Mutation) *Puzzle {
+	newPuzzle, err := puzzle.TryMutate(mutations...)
+	if err != nil {
+		panic(err)
+	}
+	return newPuzzle
+}
+
+// Like Mutate, but returns an OverlapError if the pieces would overlap, or an
+// UnknownPieceError if a mutation refers to a piece not in the puzzle. The
+// puzzle itself is never modified.
+func (puzzle Puzzle) TryMutate(mutations ...Mutation) (*Puzzle, error) {
 	newPuzzle := &Puzzle{make(map[uint8]*Piece, len(puzzle.Pieces)), make(CellMap)}
 	mutated := make(map[uint8] bool)
 	for _, mutation := range mutations {
-		existPiece := puzzle.Pieces[mutation.PieceID]
-		newPiece := Piece{existPiece.Definition, make(Cells, len(existPiece.Cells))}
+		existPiece, ok := puzzle.Pieces[mutation.PieceID]
+		if !ok {
+			return nil, &UnknownPieceError{mutation.PieceID}
+		}
+		newPiece := Piece{existPiece.Definition,
+			mutation.Transform.multiply(&existPiece.Transform),
+			make(Cells, len(existPiece.Cells)),
+			existPiece.symmetries}
 		copy(newPiece.Cells, existPiece.Cells)
 		newPiece.Cells.transform(&mutation.Transform)
-		newPuzzle.add(&newPiece)
+		if err := newPuzzle.tryAdd(&newPiece); err != nil {
+			return nil, err
+		}
 		mutated[mutation.PieceID] = true
 	}
 	for _, piece := range puzzle.Pieces {
 		if _, ok := mutated[piece.Definition.EscColor]; !ok {
-			newPuzzle.add(piece)
+			if err := newPuzzle.tryAdd(piece); err != nil {
+				return nil, err
+			}
 		}
 	}
-	return newPuzzle
+	return newPuzzle, nil
+}
+
+// Splits the puzzle into two new puzzles, one with the pieces with the given
+// IDs and one with the rest of the pieces. The pieces are shared, not copied.
+// Used to remove a group of pieces that has been freed from the puzzle.
+func (puzzle Puzzle) Split(pieceIDs []uint8) (rest, group *Puzzle) {
+	rest = &Puzzle{make(map[uint8]*Piece, len(puzzle.Pieces)), make(CellMap)}
+	group = &Puzzle{make(map[uint8]*Piece, len(pieceIDs)), make(CellMap)}
+	inGroup := make(map[uint8]bool, len(pieceIDs))
+	for _, pieceID := range pieceIDs {
+		inGroup[pieceID] = true
+	}
+	for pieceID, piece := range puzzle.Pieces {
+		if inGroup[pieceID] {
+			group.add(piece)
+		} else {
+			rest.add(piece)
+		}
+	}
+	return
 }
@@ -1,7 +1,10 @@
 package gknot
 
 import (
+	"fmt"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -82,31 +85,60 @@ func TestOverlapError(t *testing.T) {
 			{0, 1, 0, 1},
 			{-1, 0, 0, 6},
 			{0, 0, 1, 2},
-			{0, 0, 0, 1}}}
-	puzzle := &Puzzle{make(map[uint8]*Piece, 6), make(CellMap)}
+			{0, 0, 0, 1}},
+		nil}
+	_, err := NewPuzzleFrom(GreenPieceDef, badRedPiece)
+	checkOverlapError(t, err, Cell{4, 5, 2}, "Overlapping pieces [Red Green] at [4 5 2].")
+}
+
+func checkOverlapError(t *testing.T, err error, expectedCell Cell, expectedMsg string) {
+	overlapErr, ok := err.(*OverlapError)
+	if !ok {
+		t.Fatalf("Expected OverlapError, actual %v.", reflect.TypeOf(err))
+	}
+	if piecesLen := len(overlapErr.Pieces); piecesLen != 2 {
+		t.Fatalf("OverlapError has incorrect number of pieces: expected 2, actual %v.", piecesLen)
+	}
+	if overlapCell := overlapErr.Cell; overlapCell != expectedCell {
+		t.Fatalf("OverlapError has incorrect overlap cell; expected %v, actual %v.", expectedCell, overlapCell)
+	}
+	if errMsg := overlapErr.Error(); errMsg != expectedMsg {
+		t.Fatalf("Incorrect error message for OverlapError; actual message '%v'.", errMsg)
+	}
+}
 
+func TestAdd_panics(t *testing.T) {
 	defer func() {
-		if err := recover(); err != nil {
-			overlapErr, ok := err.(*OverlapError)
-			if !ok {
-				t.Fatalf("Expected OverlapError, actual %v.", reflect.TypeOf(overlapErr))
-			}
-			if piecesLen := len(overlapErr.pieces); piecesLen != 2 {
-				t.Fatalf("OverlapError has incorrect number of pieces: expected 2, actual %v.", piecesLen)
-			}
-			expectedCell := Cell{4, 5, 2}
-			if overlapCell := overlapErr.cell; *overlapCell != expectedCell {
-				t.Fatalf("OverlapError has incorrect overlap cell; expected %v, actual %v.", expectedCell, overlapCell)
-			}
-			if errMsg := overlapErr.Error(); errMsg != "Overlapping pieces [Red Green] at [4 5 2]." {
-				t.Fatalf("Incorrect error message for OverlapError; actual message '%v'.", errMsg)
-			}
+		if _, ok := recover().(*OverlapError); !ok {
+			t.Fatalf("Expected panic with OverlapError.")
 		}
 	}()
-	puzzle.add(GreenPieceDef.Piece(), badRedPiece.Piece())
+	limePieceDef := GreenPieceDef
+	limePieceDef.Name, limePieceDef.EscColor = "Lime", 92
+	puzzle := &Puzzle{make(map[uint8]*Piece, 6), make(CellMap)}
+	puzzle.add(GreenPieceDef.Piece(), limePieceDef.Piece())
 	t.Fatal("Should have paniked with OverlapError since two pieces overlap.")
 }
 
+func TestTryMutate(t *testing.T) {
+	// Move the Orange piece down onto the Blue piece.
+	origPuzzle := NewPuzzle()
+	_, err := origPuzzle.TryMutate(Mutation{35, Translation{0, -2, 0}.TransformMatrix()})
+	checkOverlapError(t, err, Cell{0, 2, 1}, "Overlapping pieces [Blue Orange] at [0 2 1].")
+	if _, err := origPuzzle.TryMutate(Mutation{99, Translation{}.TransformMatrix()}); err == nil {
+		t.Fatalf("Expected UnknownPieceError for a mutation of piece 99.")
+	} else if unknownErr, ok := err.(*UnknownPieceError); !ok || unknownErr.PieceID != 99 {
+		t.Fatalf("Expected UnknownPieceError for piece 99, actual %v.", err)
+	}
+	newPuzzle, err := origPuzzle.TryMutate(Mutation{35, Translation{0, 2, 0}.TransformMatrix()})
+	if err != nil {
+		t.Fatalf("Moving the Orange piece up should succeed, actual %v.", err)
+	}
+	if newPuzzle.Key() == origPuzzle.Key() {
+		t.Fatalf("Mutated puzzle should be in a different state.")
+	}
+}
+
 func TestNewPuzzle(t *testing.T) {
 	puzzle := NewPuzzle()
 	if numPieces := len(puzzle.Pieces); numPieces != 6 {
@@ -194,3 +226,216 @@ func TestMutate_deepCopy(t *testing.T) {
 		}
 	}
 }
+
+// Translating all pieces the same way should yield the same key, however far.
+func TestKey_translation(t *testing.T) {
+	origPuzzle := NewPuzzle()
+	translate := TransformMatrix{
+		{1, 0, 0, 1000},
+		{0, 1, 0, -5},
+		{0, 0, 1, -300},
+		{0, 0, 0, 1}}
+	mutations := make([]Mutation, 0, len(origPuzzle.Pieces))
+	for pieceID := range origPuzzle.Pieces {
+		mutations = append(mutations, Mutation{pieceID, translate})
+	}
+	if origKey, newKey := origPuzzle.Key(), origPuzzle.Mutate(mutations...).Key(); newKey != origKey {
+		t.Fatalf("New translated puzzle should have same key %q, actual %q", origKey, newKey)
+	}
+}
+
+// Moving a piece by 256 cells wraps around the bytes the state ID is
+// calculated from, but must give a different key.
+func TestKey_noWraparound(t *testing.T) {
+	origPuzzle := NewPuzzle()
+	newPuzzle := origPuzzle.Mutate(Mutation{35, Translation{256, 0, 0}.TransformMatrix()})
+	if origID, newID := origPuzzle.StateID(), newPuzzle.StateID(); origID != newID {
+		t.Fatalf("Expected the state IDs to collide, actual %v and %v.", origID, newID)
+	}
+	if origKey, newKey := origPuzzle.Key(), newPuzzle.Key(); origKey == newKey {
+		t.Fatalf("Distinct states should have distinct keys, both are %q.", origKey)
+	}
+}
+
+// Turning a piece onto its own cells should not change the key, but turning
+// it elsewhere should.
+func TestKey_symmetricPiece(t *testing.T) {
+	origPuzzle := barAndCube(Cell{5, 5, 5})
+	// About the bar's length, and a half turn about its middle.
+	for _, rotation := range []Rotation{{X, 1, Cell{0, 0, 0}}, {X, -1, Cell{0, 0, 0}}, {Y, 1, Cell{2, 0, 0}}} {
+		transform := rotation.TransformMatrix()
+		newPuzzle := origPuzzle.Mutate(Mutation{31, transform})
+		if rotation.Axis == Y {
+			newPuzzle = newPuzzle.Mutate(Mutation{31, transform})
+		}
+		if configuration(newPuzzle) != configuration(origPuzzle) {
+			t.Fatalf("Turn %v should put the bar on its own cells.", rotation)
+		}
+		if origKey, newKey := origPuzzle.Key(), newPuzzle.Key(); newKey != origKey {
+			t.Fatalf("Bar turned by %v onto itself should have key %q, actual %q.", rotation, origKey, newKey)
+		}
+	}
+	newPuzzle := origPuzzle.Mutate(Mutation{31, Rotation{Z, 1, Cell{2, 0, 0}}.TransformMatrix()})
+	if origKey, newKey := origPuzzle.Key(), newPuzzle.Key(); newKey == origKey {
+		t.Fatalf("Bar turned upright should have a different key, both are %q.", origKey)
+	}
+}
+
+// Returns the cells of each piece, sorted, with the puzzle normalized to
+// having 0 minimum x, y and z. Two puzzles are in the same state if and only
+// if their configurations are equal.
+func configuration(puzzle *Puzzle) string {
+	minX, minY, minZ := puzzle.minCoords()
+	var config []string
+	for _, piece := range puzzle.sortedPieces() {
+		cells := make([]string, len(piece.Cells))
+		for i, cell := range piece.Cells {
+			cells[i] = fmt.Sprint(Cell{cell[0] - minX, cell[1] - minY, cell[2] - minZ})
+		}
+		sort.Strings(cells)
+		config = append(config, fmt.Sprint(piece.Definition.Name, cells))
+	}
+	return strings.Join(config, ";")
+}
+
+// Explores states telling them apart by their configurations rather than their
+// keys, then checks that states have the same key if and only if they have
+// the same configuration.
+func TestKey_distinctStates(t *testing.T) {
+	keys := make(map[string]StateKey)
+	configs := make(map[StateKey]string)
+	for frontier := []*Puzzle{NewPuzzle()}; len(frontier) > 0 && len(keys) < 1000; {
+		puzzle := frontier[0]
+		frontier = frontier[1:]
+		config, key := configuration(puzzle), puzzle.Key()
+		if otherKey, ok := keys[config]; ok {
+			if otherKey != key {
+				t.Fatalf("State %v has distinct keys %q and %q.", config, key, otherKey)
+			}
+			continue
+		}
+		if otherConfig, ok := configs[key]; ok {
+			t.Fatalf("Distinct states %v and %v have the same key %q.", config, otherConfig, key)
+		}
+		keys[config] = key
+		configs[key] = config
+		for _, move := range puzzle.moves(key) {
+			frontier = append(frontier, puzzle.Mutate(move.Mutations()...))
+		}
+	}
+}
+
+// Returns a copy of the Blue piece definition moved clear of the other pieces.
+func movedBluePieceDef() PieceDefinition {
+	defn := BluePieceDef
+	defn.Transform = Translation{0, 10, 0}.TransformMatrix().multiply(&defn.Transform)
+	return defn
+}
+
+func TestSameNameError(t *testing.T) {
+	defn := movedBluePieceDef()
+	defn.EscColor = 37
+	_, err := NewPuzzleFrom(BluePieceDef, defn)
+	if sameNameErr, ok := err.(*SameNameError); !ok || sameNameErr.PieceName != "Blue" {
+		t.Fatalf("Expected SameNameError for Blue, actual %v.", err)
+	}
+	// Also when adding to a puzzle with the piece already in it.
+	puzzle := NewPuzzle()
+	if err := puzzle.tryAdd(defn.Piece()); err == nil {
+		t.Fatalf("Expected SameNameError when adding a second Blue piece.")
+	} else if _, ok := err.(*SameNameError); !ok {
+		t.Fatalf("Expected SameNameError, actual %v.", err)
+	}
+}
+
+func TestSameEscColorError(t *testing.T) {
+	defn := movedBluePieceDef()
+	defn.Name = "Cyan"
+	_, err := NewPuzzleFrom(BluePieceDef, defn)
+	if sameColorErr, ok := err.(*SameEscColorError); !ok || sameColorErr.EscColor != 36 {
+		t.Fatalf("Expected SameEscColorError for 36, actual %v.", err)
+	}
+	puzzle := NewPuzzle()
+	if err := puzzle.tryAdd(defn.Piece()); err == nil {
+		t.Fatalf("Expected SameEscColorError when adding a second piece with color 36.")
+	} else if _, ok := err.(*SameEscColorError); !ok {
+		t.Fatalf("Expected SameEscColorError, actual %v.", err)
+	}
+	if numPieces := len(puzzle.Pieces); numPieces != 6 {
+		t.Fatalf("Puzzle should still have 6 pieces, actual %v.", numPieces)
+	}
+}
+
+func TestInvalidGeomError(t *testing.T) {
+	defn := BluePieceDef
+	defn.Geom[3][4] = 2
+	err := defn.Validate()
+	if geomErr, ok := err.(*InvalidGeomError); !ok || geomErr.X != 4 || geomErr.Y != 3 || geomErr.Value != 2 {
+		t.Fatalf("Expected InvalidGeomError at (4, 3), actual %v.", err)
+	}
+	if _, err := NewPuzzleFrom(defn); err == nil {
+		t.Fatalf("NewPuzzleFrom should fail with InvalidGeomError.")
+	}
+}
+
+func TestEmptyPieceError(t *testing.T) {
+	defn := BluePieceDef
+	defn.Geom = PieceGeom{}
+	if _, ok := defn.Validate().(*EmptyPieceError); !ok {
+		t.Fatalf("Expected EmptyPieceError, actual %v.", defn.Validate())
+	}
+}
+
+func TestDuplicateVoxelError(t *testing.T) {
+	defn := BluePieceDef
+	defn.Voxels = Cells{{0, 0, 0}, {0, 1, 0}, {0, 0, 0}}
+	err, ok := defn.Validate().(*DuplicateVoxelError)
+	if !ok {
+		t.Fatalf("Expected DuplicateVoxelError, actual %v.", defn.Validate())
+	}
+	if err.Cell != (Cell{0, 0, 0}) {
+		t.Fatalf("Expected duplicate voxel at [0 0 0], actual %v.", err.Cell)
+	}
+	defn.Voxels = Cells{}
+	if _, ok := defn.Validate().(*EmptyPieceError); !ok {
+		t.Fatalf("Expected EmptyPieceError, actual %v.", defn.Validate())
+	}
+}
+
+func TestPiece_voxels(t *testing.T) {
+	defn := BluePieceDef
+	defn.Voxels = Cells{{0, 0, 0}, {0, 0, 1}}
+	defn.Transform = Translation{1, 2, 3}.TransformMatrix()
+	checkPiece(t, defn.Piece(), Cells{{1, 2, 3}, {1, 2, 4}})
+	if defn.Voxels[1] != (Cell{0, 0, 1}) {
+		t.Fatalf("Piece should not change the definition's voxels, actual %v.", defn.Voxels)
+	}
+}
+
+func TestInvalidTransformError(t *testing.T) {
+	invalidTransforms := []TransformMatrix{
+		// Scales.
+		{{2, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}, {0, 0, 0, 1}},
+		// Reflects.
+		{{-1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}, {0, 0, 0, 1}},
+		// Projects onto the x-y plane.
+		{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 0, 0}, {0, 0, 0, 1}},
+		// Maps two axes onto the same one.
+		{{1, 0, 0, 0}, {1, 0, 0, 0}, {0, 0, 1, 0}, {0, 0, 0, 1}},
+		// Not affine.
+		{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 1, 0}, {0, 0, 1, 1}},
+	}
+	for _, transform := range invalidTransforms {
+		defn := BluePieceDef
+		defn.Transform = transform
+		if _, ok := defn.Validate().(*InvalidTransformError); !ok {
+			t.Errorf("Expected InvalidTransformError for %v, actual %v.", transform, defn.Validate())
+		}
+	}
+	for _, defn := range []PieceDefinition{BluePieceDef, OrangePieceDef, PurplePieceDef,
+		GreenPieceDef, RedPieceDef, YellowPieceDef} {
+		if err := defn.Validate(); err != nil {
+			t.Errorf("%v piece should be valid, actual %v.", defn.Name, err)
+		}
+	}
+}
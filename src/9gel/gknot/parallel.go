@@ -0,0 +1,128 @@
+// Expanding the states of breadth first searches on several goroutines.
+package gknot
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// The moves from a state of a breadth first search, worked out ahead of the
+// search taking them.
+type expansion struct {
+	// The removal of a free group, if any.
+	removal Move
+	free    bool
+	// The moves from the state, with To set, and the puzzles in the states
+	// they lead to. Unless the search keeps them, the moves to states that had
+	// been visited when the expansion started are left out.
+	moves []Move
+	next  []*Puzzle
+	// The number of moves to states that had not been visited.
+	newStates int
+}
+
+// How expandAll expands the states of a frontier.
+type expandOptions struct {
+	// Whether a state has been visited. Only read, so that it needs no
+	// locking.
+	visited func(StateKey) bool
+	// Also keep the moves to visited states.
+	keepVisited bool
+	// Stop at the first state with a free group.
+	stopAtFree bool
+	// Stop once the states expanded have this many moves to states that had
+	// not been visited. 0 means no limit.
+	maxNewStates int
+}
+
+// The number of goroutines to expand states on.
+func (s *solver) numWorkers() int {
+	if s.opts.Workers > 0 {
+		return s.opts.Workers
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// Expands the states of the frontier, reached by depth moves, in order. The
+// states are shared among the workers, each taking the next one not taken
+// yet, until none are left, the solver's context is done or the options ask
+// to stop. Returns the expansions of the states up to the first one that was
+// not expanded, and up to the first one with a free group if the options ask
+// to stop there; the search takes the moves in that order, which makes its
+// results the same as if it had expanded the states one at a time.
+func (s *solver) expandAll(frontier []*Puzzle, depth int, opts expandOptions) []expansion {
+	expansions := make([]expansion, len(frontier))
+	expanded := make([]bool, len(frontier))
+	// The next index to take, the index of the first state with a free group
+	// found so far, and the number of moves to new states found so far.
+	nextIndex, firstFree, newStates := int64(-1), int64(len(frontier)), int64(0)
+	stop := func(i int) bool {
+		return s.ctx.Err() != nil || int64(i) > atomic.LoadInt64(&firstFree) ||
+			opts.maxNewStates > 0 && atomic.LoadInt64(&newStates) >= int64(opts.maxNewStates)
+	}
+	work := func() {
+		for i := int(atomic.AddInt64(&nextIndex, 1)); i < len(frontier) && !stop(i); i = int(atomic.AddInt64(&nextIndex, 1)) {
+			expansions[i] = s.expand(frontier[i], depth, opts)
+			expanded[i] = true
+			atomic.AddInt64(&newStates, int64(expansions[i].newStates))
+			if !opts.stopAtFree || !expansions[i].free {
+				continue
+			}
+			for found := atomic.LoadInt64(&firstFree); int64(i) < found; found = atomic.LoadInt64(&firstFree) {
+				if atomic.CompareAndSwapInt64(&firstFree, found, int64(i)) {
+					break
+				}
+			}
+		}
+	}
+	numWorkers := s.numWorkers()
+	if numWorkers > len(frontier) {
+		numWorkers = len(frontier)
+	}
+	if numWorkers <= 1 {
+		work()
+	} else {
+		var wg sync.WaitGroup
+		for worker := 0; worker < numWorkers; worker++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				work()
+			}()
+		}
+		wg.Wait()
+	}
+	n := 0
+	for n < len(frontier) && expanded[n] && int64(n) <= firstFree {
+		n++
+	}
+	return expansions[:n]
+}
+
+// Expands a state reached by depth moves. Only reads from the solver and the
+// puzzle, so that states may be expanded concurrently.
+func (s *solver) expand(puzzle *Puzzle, depth int, opts expandOptions) expansion {
+	var e expansion
+	moves := s.moves(puzzle, puzzle.Key())
+	if e.removal, e.free = puzzle.freeGroup(moves); e.free {
+		return e
+	}
+	if s.opts.MaxDepth > 0 && depth >= s.opts.MaxDepth {
+		return e
+	}
+	for _, move := range moves {
+		next := puzzle.Mutate(move.Mutations()...)
+		move.To = next.Key()
+		if opts.visited(move.To) {
+			if !opts.keepVisited {
+				continue
+			}
+		} else {
+			e.newStates++
+		}
+		e.moves = append(e.moves, move)
+		e.next = append(e.next, next)
+	}
+	return e
+}
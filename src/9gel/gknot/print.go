@@ -1,28 +1,86 @@
 package gknot
 
-import "fmt"
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
 
 const (
 	block = '\u2588'
+	shade = '\u2593'
 	esc   = '\x1b'
 )
 
-// Prints a piece laid flat.
+// Draws pieces and puzzles with coloured blocks in a terminal, using ANSI
+// escape codes, as Print does when standard output is a terminal.
+type ANSIRenderer struct {
+	// Pieces drawn shaded in bold so that they stand out.
+	Highlight []uint8
+}
+
+// Prints a piece laid flat, or a piece defined by voxels one layer at a time,
+// with DefaultRenderer.
 func (piece PieceDefinition) Print() {
-	fmt.Printf("%c[1;%dm%v%c[0m piece:\n", esc, piece.EscColor, piece.Name, esc)
-	fmt.Printf("%c[0;%dm", esc, piece.EscColor)
+	DefaultRenderer().RenderPiece(os.Stdout, piece)
+}
+
+// Writes the piece as Print does.
+func (renderer ANSIRenderer) RenderPiece(w io.Writer, piece PieceDefinition) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%c[1;%dm%v%c[0m piece:\n", esc, piece.EscColor, piece.Name, esc)
+	fmt.Fprintf(bw, "%c[0;%dm", esc, piece.EscColor)
+	piece.writeCells(bw, string([]rune{block, block}))
+	fmt.Fprintf(bw, "%c[0m\n", esc)
+	return bw.Flush()
+}
+
+// Writes the cells of a piece laid flat, or the layers of its voxels from the
+// lowest z upwards, each as for a piece laid flat. Each cell is drawn as the
+// given string, which is 2 characters wide.
+func (piece PieceDefinition) writeCells(w io.Writer, filled string) {
+	if piece.Voxels != nil {
+		piece.writeLayers(w, filled)
+		return
+	}
 	// Print higher index rows first since the coordinate has y axis going upwards.
 	for i := len(piece.Geom) - 1; i >= 0; i-- {
 		for _, v := range piece.Geom[i] {
 			if v == 1 {
-				fmt.Printf("%c%c", block, block)
+				io.WriteString(w, filled)
 			} else {
-				fmt.Print("  ")
+				io.WriteString(w, "  ")
 			}
 		}
-		fmt.Println()
+		io.WriteString(w, "\n")
+	}
+}
+
+func (piece PieceDefinition) writeLayers(w io.Writer, filled string) {
+	solid := make(map[Cell]bool, len(piece.Voxels))
+	for _, voxel := range piece.Voxels {
+		solid[voxel] = true
+	}
+	minX, maxX := piece.Voxels.span(X)
+	minY, maxY := piece.Voxels.span(Y)
+	minZ, maxZ := piece.Voxels.span(Z)
+	for z := minZ; z <= maxZ; z++ {
+		if z > minZ {
+			io.WriteString(w, "\n")
+		}
+		fmt.Fprintf(w, "z = %v\n", z)
+		for y := maxY; y >= minY; y-- {
+			for x := minX; x <= maxX; x++ {
+				if solid[Cell{x, y, z}] {
+					io.WriteString(w, filled)
+				} else {
+					io.WriteString(w, "  ")
+				}
+			}
+			io.WriteString(w, "\n")
+		}
 	}
-	fmt.Printf("%c[0m\n", esc)
 }
 
 type Coords2D [2]int
@@ -104,7 +162,63 @@ func (screenCells ProjectedCells) transformAndAddCells(transform Transform2D, ce
 // - x-y: x to the right, y upwards
 // - y-z: y upwards, z to the left
 // - x-z: x to the right, z downwards
+// It is drawn with DefaultRenderer.
 func (puzzle Puzzle) Print() {
+	DefaultRenderer().RenderPuzzle(os.Stdout, &puzzle)
+}
+
+// Prints the puzzle like Print, with the given pieces drawn so that they stand
+// out: shaded in bold in colour, or in lower case in plain text.
+func (puzzle Puzzle) PrintHighlighted(pieceIDs ...uint8) {
+	if colorOutput() {
+		ANSIRenderer{Highlight: pieceIDs}.RenderPuzzle(os.Stdout, &puzzle)
+	} else {
+		TextRenderer{Highlight: pieceIDs}.RenderPuzzle(os.Stdout, &puzzle)
+	}
+}
+
+// Writes the puzzle as Print does.
+func (renderer ANSIRenderer) RenderPuzzle(w io.Writer, puzzle *Puzzle) error {
+	highlight := make(map[uint8]bool, len(renderer.Highlight))
+	for _, pieceID := range renderer.Highlight {
+		highlight[pieceID] = true
+	}
+	screenCells, width := puzzle.screenCells()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "= %c[1;31m%v%c[0m =\n", esc, puzzle.StateID(), esc)
+	// Each cell is printed 2 characters wide.
+	fmt.Fprintf(bw, "%c[1mx-y%*cy-z%*cx-z%c[0m\n", esc, 2*width-3, ' ', 2*width-3, ' ', esc)
+	writeScreenCells(bw, screenCells, func(cell ProjectedCell) string {
+		if highlight[cell.Piece.Definition.EscColor] {
+			return fmt.Sprintf("%c[1;%dm%c%c%c[0m", esc, cell.Piece.Definition.EscColor, shade, shade, esc)
+		}
+		return fmt.Sprintf("%c[0;%dm%c%c%c[0m", esc, cell.Piece.Definition.EscColor, block, block, esc)
+	})
+	return bw.Flush()
+}
+
+// Writes the rows of cells laid out on the screen, drawing each cell as the
+// string returned for it, which is 2 characters wide. Empty cells at the ends
+// of rows are left out.
+func writeScreenCells(w io.Writer, screenCells ProjectedCells, draw func(cell ProjectedCell) string) {
+	screenMaxX, screenMaxY := screenCells.axesMax()
+	for y := 0; y <= screenMaxY; y++ {
+		spacer := ""
+		for x := 0; x <= screenMaxX; x++ {
+			if cell, ok := screenCells[Coords2D{x, y}]; ok {
+				io.WriteString(w, spacer+draw(cell))
+				spacer = ""
+			} else {
+				spacer += "  "
+			}
+		}
+		io.WriteString(w, "\n")
+	}
+}
+
+// Lays out the 3 projections of the puzzle side by side on the screen, each
+// occupying width cells across.
+func (puzzle Puzzle) screenCells() (screenCells ProjectedCells, width int) {
 	xyProjected := ProjectPuzzle(X, Y, puzzle)
 	yzProjected := ProjectPuzzle(Y, Z, puzzle)
 	xzProjected := ProjectPuzzle(X, Z, puzzle)
@@ -113,10 +227,10 @@ func (puzzle Puzzle) Print() {
 	// coordinate system is x-y where x axis goes to the right and
 	// y axis goes downwards, starting at (0,0). Translate and reflect the
 	// 2D projections to this coordinate system and print.
-	// Print all 3 projections side-by-side, each occupying at most 20 spaces
-	// along the x axis, 60 spaces in total. They will occupy at most 20 
-	// spaces down the y axis.
-	screenCells := make(ProjectedCells)
+	// Print all 3 projections side-by-side, each occupying 20 spaces along the
+	// x axis, or more for puzzles too wide to fit.
+	screenCells = make(ProjectedCells)
+	width = panelWidth(xyProjected, yzProjected, xzProjected)
 
 	_, xyMaxY := xyProjected.axesMax()
 	xyMinX, _ := xyProjected.axesMin()
@@ -126,28 +240,83 @@ func (puzzle Puzzle) Print() {
 
 	yzMaxY, yzMaxZ := yzProjected.axesMax()
 	screenCells.transformAndAddCells(Transform2D{
-		{0, -1, yzMaxZ + 20},
+		{0, -1, yzMaxZ + width},
 		{-1, 0, yzMaxY}}, yzProjected)
 
 	xzMinX, xzMinZ := xzProjected.axesMin()
 	screenCells.transformAndAddCells(Transform2D{
-		{1, 0, 40 - xzMinX},
+		{1, 0, 2*width - xzMinX},
 		{0, 1, -xzMinZ}}, xzProjected)
+	return screenCells, width
+}
 
-	screenMaxX, screenMaxY := screenCells.axesMax()
-	fmt.Printf("= %c[1;31m%v%c[0m =\n", esc, puzzle.StateID(), esc)
-	fmt.Printf("%c[1mx-y%37cy-z%37cx-z%c[0m\n", esc, ' ', ' ', esc)
-	for y := 0; y <= screenMaxY; y++ {
-		spacer := ""
-		for x := 0; x <= screenMaxX; x++ {
-			cell, ok := screenCells[Coords2D{x, y}]
-			if ok {
-				fmt.Printf("%v%c[0;%dm%c%c%c[0m", spacer, esc, cell.Piece.Definition.EscColor, block, block, esc)
-				spacer = ""
-			} else {
-				spacer += "  "
-			}
+// The number of cells each projection occupies across the screen: 20, or one
+// more than the largest extent of the projections if that does not fit.
+func panelWidth(projections ...ProjectedCells) int {
+	width := 20
+	for _, projected := range projections {
+		max1, max2 := projected.axesMax()
+		min1, min2 := projected.axesMin()
+		if max1-min1+2 > width {
+			width = max1 - min1 + 2
+		}
+		if max2-min2+2 > width {
+			width = max2 - min2 + 2
+		}
+	}
+	return width
+}
+
+// Prints each move of the solution made from the starting puzzle, followed by
+// the state it leads to, drawn with DefaultRenderer.
+func (solution Solution) Print(start *Puzzle) {
+	solution.Render(os.Stdout, start, DefaultRenderer())
+}
+
+// Writes the solution as Print does, drawing the states with the renderer.
+func (solution Solution) Render(w io.Writer, start *Puzzle, renderer Renderer) error {
+	if len(solution.States) == 0 {
+		return nil
+	}
+	deadEnds := make(map[StateKey]bool, len(solution.DeadEnds))
+	for _, key := range solution.DeadEnds {
+		deadEnds[key] = true
+	}
+	renderState := func(key StateKey, puzzle *Puzzle) error {
+		if err := renderer.RenderPuzzle(w, puzzle); err != nil {
+			return err
+		}
+		if deadEnds[key] {
+			_, err := fmt.Fprintln(w, "No more moves beyond state", puzzle.StateID())
+			return err
+		}
+		return nil
+	}
+	if err := renderState(solution.States[0], start); err != nil {
+		return err
+	}
+	for _, step := range solution.Steps(start) {
+		move, from := step.Move, step.From
+		pieceNames := make([]string, len(move.PieceIDs))
+		for i, pieceID := range move.PieceIDs {
+			pieceNames[i] = from.Pieces[pieceID].Definition.Name
+		}
+		var err error
+		switch {
+		case move.Removed:
+			_, err = fmt.Fprintln(w, "From", from.StateID(), "Remove", move.Translation, "Pieces", pieceNames)
+		case move.Rotation.Turn != 0:
+			_, err = fmt.Fprintln(w, "From", from.StateID(), "Rotate", move.Rotation.Turn, "about axis",
+				"xyz"[move.Rotation.Axis:move.Rotation.Axis+1], "Pieces", pieceNames)
+		default:
+			_, err = fmt.Fprintln(w, "From", from.StateID(), "Mutate", move.Translation, "Pieces", pieceNames)
+		}
+		if err != nil {
+			return err
+		}
+		if err := renderState(move.To, step.To); err != nil {
+			return err
 		}
-		fmt.Println()
 	}
+	return nil
 }
@@ -1,7 +1,9 @@
 package gknot
 
-func ExamplePuzzlePrint() {
-	NewPuzzle().Print()
+import "os"
+
+func ExampleANSIRenderer_RenderPuzzle() {
+	ANSIRenderer{}.RenderPuzzle(os.Stdout, NewPuzzle())
 	// Output:
 	// = [1;31mD879AEE0[0m =
 	// [1mx-y                                     y-z                                     x-z[0m
@@ -14,14 +16,14 @@ func ExamplePuzzlePrint() {
 	//   [0;33m██[0m[0;33m██[0m[0;33m██[0m[0;33m██[0m[0;33m██[0m                                [0;33m██[0m  [0;31m██[0m                                  [0;34m██[0m  [0;32m██[0m
 }
 
-func ExamplePuzzleOrangeTranslatedPrint() {
+func ExampleANSIRenderer_RenderPuzzle_orangeTranslated() {
 	// Move the Orange piece by 2 along x axis.
 	mutation := Mutation{35, TransformMatrix{
 		{1, 0, 0, 2},
 		{0, 1, 0, 0},
 		{0, 0, 1, 0},
 		{0, 0, 0, 1}}}
-	NewPuzzle().Mutate(mutation).Print()
+	ANSIRenderer{}.RenderPuzzle(os.Stdout, NewPuzzle().Mutate(mutation))
 	// Output:
 	// = [1;31m96D7DD8C[0m =
 	// [1mx-y                                     y-z                                     x-z[0m
@@ -35,14 +37,14 @@ func ExamplePuzzleOrangeTranslatedPrint() {
 }
 
 // Move all pieces but Orange by -2 along x axis.
-func ExamplePuzzleEveryPieceButOrangeTranslatedPrint() {
+func ExampleANSIRenderer_RenderPuzzle_everyPieceButOrangeTranslated() {
 	transform := TransformMatrix{
 		{1, 0, 0, -2},
 		{0, 1, 0, 0},
 		{0, 0, 1, 0},
 		{0, 0, 0, 1}}
 	mutations := []Mutation{{31, transform}, {32, transform}, {33, transform}, {34, transform}, {36, transform}}
-	NewPuzzle().Mutate(mutations...).Print()
+	ANSIRenderer{}.RenderPuzzle(os.Stdout, NewPuzzle().Mutate(mutations...))
 	// Output:
 	// = [1;31m96D7DD8C[0m =
 	// [1mx-y                                     y-z                                     x-z[0m
@@ -55,7 +57,7 @@ func ExamplePuzzleEveryPieceButOrangeTranslatedPrint() {
 	//   [0;33m██[0m[0;33m██[0m[0;33m██[0m[0;33m██[0m[0;33m██[0m                                [0;33m██[0m  [0;31m██[0m                                  [0;34m██[0m  [0;32m██[0m
 }
 
-func ExamplePuzzlePositiveYTranslatedPrint() {
+func ExampleANSIRenderer_RenderPuzzle_positiveYTranslated() {
 	transform := TransformMatrix{
 		{1, 0, 0, 0},
 		{0, 1, 0, 1},
@@ -68,7 +70,7 @@ func ExamplePuzzlePositiveYTranslatedPrint() {
 		{34, transform},
 		{35, transform},
 		{36, transform}}
-	NewPuzzle().Mutate(mutations...).Print()
+	ANSIRenderer{}.RenderPuzzle(os.Stdout, NewPuzzle().Mutate(mutations...))
 	// Output:
 	// = [1;31mD879AEE0[0m =
 	// [1mx-y                                     y-z                                     x-z[0m
@@ -81,7 +83,7 @@ func ExamplePuzzlePositiveYTranslatedPrint() {
 	//   [0;33m██[0m[0;33m██[0m[0;33m██[0m[0;33m██[0m[0;33m██[0m                                [0;33m██[0m  [0;31m██[0m                                  [0;34m██[0m  [0;32m██[0m
 }
 
-func ExamplePuzzleNegativeYTranslatedPrint() {
+func ExampleANSIRenderer_RenderPuzzle_negativeYTranslated() {
 	transform := TransformMatrix{
 		{1, 0, 0, 0},
 		{0, 1, 0, -9},
@@ -94,7 +96,7 @@ func ExamplePuzzleNegativeYTranslatedPrint() {
 		{34, transform},
 		{35, transform},
 		{36, transform}}
-	NewPuzzle().Mutate(mutations...).Print()
+	ANSIRenderer{}.RenderPuzzle(os.Stdout, NewPuzzle().Mutate(mutations...))
 	// Output:
 	// = [1;31mD879AEE0[0m =
 	// [1mx-y                                     y-z                                     x-z[0m
@@ -107,7 +109,7 @@ func ExamplePuzzleNegativeYTranslatedPrint() {
 	//   [0;33m██[0m[0;33m██[0m[0;33m██[0m[0;33m██[0m[0;33m██[0m                                [0;33m██[0m  [0;31m██[0m                                  [0;34m██[0m  [0;32m██[0m
 }
 
-func ExamplePuzzlePositiveZTranslatedPrint() {
+func ExampleANSIRenderer_RenderPuzzle_positiveZTranslated() {
 	transform := TransformMatrix{
 		{1, 0, 0, 0},
 		{0, 1, 0, 0},
@@ -120,7 +122,7 @@ func ExamplePuzzlePositiveZTranslatedPrint() {
 		{34, transform},
 		{35, transform},
 		{36, transform}}
-	NewPuzzle().Mutate(mutations...).Print()
+	ANSIRenderer{}.RenderPuzzle(os.Stdout, NewPuzzle().Mutate(mutations...))
 	// Output:
 	// = [1;31mD879AEE0[0m =
 	// [1mx-y                                     y-z                                     x-z[0m
@@ -133,7 +135,7 @@ func ExamplePuzzlePositiveZTranslatedPrint() {
 	//   [0;33m██[0m[0;33m██[0m[0;33m██[0m[0;33m██[0m[0;33m██[0m                                [0;33m██[0m  [0;31m██[0m                                  [0;34m██[0m  [0;32m██[0m
 }
 
-func ExamplePuzzleNegativeZTranslatedPrint() {
+func ExampleANSIRenderer_RenderPuzzle_negativeZTranslated() {
 	transform := TransformMatrix{
 		{1, 0, 0, 0},
 		{0, 1, 0, 0},
@@ -146,7 +148,7 @@ func ExamplePuzzleNegativeZTranslatedPrint() {
 		{34, transform},
 		{35, transform},
 		{36, transform}}
-	NewPuzzle().Mutate(mutations...).Print()
+	ANSIRenderer{}.RenderPuzzle(os.Stdout, NewPuzzle().Mutate(mutations...))
 	// Output:
 	// = [1;31mD879AEE0[0m =
 	// [1mx-y                                     y-z                                     x-z[0m
@@ -158,3 +160,31 @@ func ExamplePuzzleNegativeZTranslatedPrint() {
 	//   [0;33m██[0m[0;34m██[0m[0;33m██[0m[0;32m██[0m[0;33m██[0m                            [0;32m██[0m[0;32m██[0m[0;33m██[0m[0;32m██[0m[0;31m██[0m[0;32m██[0m[0;32m██[0m                          [0;35m██[0m[0;35m██[0m[0;34m██[0m[0;35m██[0m[0;32m██[0m[0;35m██[0m[0;35m██[0m
 	//   [0;33m██[0m[0;33m██[0m[0;33m██[0m[0;33m██[0m[0;33m██[0m                                [0;33m██[0m  [0;31m██[0m                                  [0;34m██[0m  [0;32m██[0m
 }
+
+func ExampleANSIRenderer_RenderPuzzle_highlighted() {
+	puzzle, _ := LoadPuzzleFile("testdata/cup.json")
+	// The peg shows through the opening of the cup in the x-z view.
+	ANSIRenderer{Highlight: []uint8{31}}.RenderPuzzle(os.Stdout, puzzle)
+	// Output:
+	// = [1;31m5FDC48EE[0m =
+	// [1mx-y                                     y-z                                     x-z[0m
+	// [0;33m██[0m[0;33m██[0m[0;33m██[0m                                  [0;33m██[0m[0;33m██[0m[0;33m██[0m                                  [0;33m██[0m[0;33m██[0m[0;33m██[0m
+	// [0;33m██[0m[0;33m██[0m[0;33m██[0m                                  [0;33m██[0m[0;33m██[0m[0;33m██[0m                                  [0;33m██[0m[1;31m▓▓[0m[0;33m██[0m
+	// [0;33m██[0m[0;33m██[0m[0;33m██[0m                                  [0;33m██[0m[0;33m██[0m[0;33m██[0m                                  [0;33m██[0m[0;33m██[0m[0;33m██[0m
+}
+
+// Standard output is not a terminal when examples are checked, so Print
+// writes plain text.
+func ExamplePuzzle_Print() {
+	NewPuzzle().Print()
+	// Output:
+	// = D879AEE0 =
+	// x-y                                     y-z                                     x-z
+	//   YYYYYYYYYY                                YY  RR                                  PP  GG
+	//   YYPPYYGGYY                            GGGGYYGGRRGGGG                          OOOOPPOOGGOOOO
+	// OOOOPPOOGGOOOO                          GGOOOOOOOOOOGG                          OORRRRRRRRRROO
+	//   YYPPYYGGYY                            GGGGYYGGRRGGGG                          OOOOPP  GGOOOO
+	// BBBBPPBBGGBBBB                          GGBBBBBBBBBBGG                          OOYYYYYYYYYYOO
+	//   YYPPYYGGYY                            GGGGYYGGRRGGGG                          OOOOPPOOGGOOOO
+	//   YYYYYYYYYY                                YY  RR                                  PP  GG
+}
@@ -6,13 +6,57 @@ package main
 
 import (
 	"9gel/gknot"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
 )
 
+var puzzleFile = flag.String("puzzle", "", "Puzzle file to print the pieces of. Defaults to the Gordian Knot.")
+var rendererName = flag.String("renderer", "auto", gknot.RendererUsage)
+var glyphs = flag.String("glyphs", "",
+	"Characters to draw pieces with in text, such as Blue=#,Red=%. Defaults to the pieces' initials.")
+var out = flag.String("out", ".", "Directory to write an svg or png file per piece to.")
+
 func main() {
-	gknot.BluePieceDef.Print()
-	gknot.OrangePieceDef.Print()
-	gknot.PurplePieceDef.Print()
-	gknot.GreenPieceDef.Print()
-	gknot.RedPieceDef.Print()
-	gknot.YellowPieceDef.Print()
+	flag.Parse()
+	renderer, err := gknot.RendererByName(*rendererName, *glyphs, gknot.ImageOptions{})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	defns, err := gknot.LoadPieceDefinitionsFile(*puzzleFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, defn := range defns {
+		if err := render(renderer, defn); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// Draws the piece to standard output, or to a file named after it if the
+// renderer draws pictures.
+func render(renderer gknot.Renderer, defn gknot.PieceDefinition) error {
+	extension := gknot.FileExtension(renderer)
+	if extension == "" {
+		return renderer.RenderPiece(os.Stdout, defn)
+	}
+	path := filepath.Join(*out, defn.Name+extension)
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := renderer.RenderPiece(f, defn); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Println("Wrote", path)
+	return nil
 }
@@ -6,21 +6,76 @@ package main
 
 import (
 	"9gel/gknot"
+	"flag"
 	"fmt"
+	"image/color"
+	"io"
+	"os"
 )
 
+var puzzleFile = flag.String("puzzle", "", "Puzzle file to print. Defaults to the Gordian Knot.")
+var rendererName = flag.String("renderer", "auto", gknot.RendererUsage)
+var glyphs = flag.String("glyphs", "",
+	"Characters to draw pieces with in text, such as Blue=#,Red=%. Defaults to the pieces' initials.")
+var burrToolsFile = flag.String("burrtools", "", "If set, also write the puzzle to this BurrTools .xmpuzzle file.")
+var svgFile = flag.String("svg", "", "If set, also draw the puzzle to this SVG file.")
+var pngFile = flag.String("png", "", "If set, also draw an isometric picture of the puzzle to this PNG file.")
+var camera = flag.String("camera", "+++",
+	"The corner the PNG picture sees the puzzle from, as the signs of x, y and z. z points up.")
+var cellSize = flag.Int("cell_size", 24, "Size of the cells in the PNG picture, in pixels.")
+var background = flag.String("background", "", "Background colour of the PNG picture as #rrggbb. Transparent if empty.")
+
 func main() {
-	puzzle := gknot.NewPuzzle()
-	puzzle.Print()
+	flag.Parse()
+	puzzle, err := gknot.LoadPuzzleFile(*puzzleFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	opts, err := imageOptions()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	renderer, err := gknot.RendererByName(*rendererName, *glyphs, opts)
+	if err == nil {
+		err = renderer.RenderPuzzle(os.Stdout, puzzle)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *burrToolsFile != "" {
+		if err := writeFile(*burrToolsFile, puzzle.WriteBurrTools); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if *svgFile != "" {
+		if err := writeFile(*svgFile, puzzle.WriteSVG); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if *pngFile != "" {
+		if err := writeFile(*pngFile, func(w io.Writer) error { return puzzle.WritePNG(w, opts) }); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if *puzzleFile != "" || gknot.FileExtension(renderer) != "" {
+		// The moves below are for the Gordian Knot only, drawn as text.
+		return
+	}
 
 	fmt.Println("Moving the Orange piece by 1 along x axis:")
-	mutation := gknot.Mutation{35, gknot.TransformMatrix{
+	mutation := gknot.Mutation{PieceID: 35, Transform: gknot.TransformMatrix{
 		{1, 0, 0, 1},
 		{0, 1, 0, 0},
 		{0, 0, 1, 0},
 		{0, 0, 0, 1}}}
 	newPuzzle := puzzle.Mutate(mutation)
-	newPuzzle.Print()
+	renderer.RenderPuzzle(os.Stdout, newPuzzle)
 
 	fmt.Println("Moving the all but Orange pice by -1 along x axis:")
 	transform := gknot.TransformMatrix{
@@ -28,6 +83,50 @@ func main() {
 		{0, 1, 0, 0},
 		{0, 0, 1, 0},
 		{0, 0, 0, 1}}
-	mutations := []gknot.Mutation{{31, transform}, {32, transform}, {33, transform}, {34, transform}, {36, transform}}
-	newPuzzle.Mutate(mutations...).Print()
+	mutations := []gknot.Mutation{
+		{PieceID: 31, Transform: transform},
+		{PieceID: 32, Transform: transform},
+		{PieceID: 33, Transform: transform},
+		{PieceID: 34, Transform: transform},
+		{PieceID: 36, Transform: transform}}
+	renderer.RenderPuzzle(os.Stdout, newPuzzle.Mutate(mutations...))
+}
+
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Reads the PNG picture's options from the flags.
+func imageOptions() (gknot.ImageOptions, error) {
+	opts := gknot.ImageOptions{CellSize: *cellSize}
+	if len(*camera) != 3 {
+		return opts, fmt.Errorf("Camera %q must be 3 signs, such as +-+.", *camera)
+	}
+	for axis, sign := range *camera {
+		switch sign {
+		case '+':
+			opts.Camera[axis] = 1
+		case '-':
+			opts.Camera[axis] = -1
+		default:
+			return opts, fmt.Errorf("Camera %q must be 3 signs, such as +-+.", *camera)
+		}
+	}
+	if *background != "" {
+		var c color.RGBA
+		if _, err := fmt.Sscanf(*background, "#%02x%02x%02x", &c.R, &c.G, &c.B); err != nil {
+			return opts, fmt.Errorf("Background %q must be like #rrggbb.", *background)
+		}
+		c.A = 0xff
+		opts.Background = c
+	}
+	return opts, nil
 }
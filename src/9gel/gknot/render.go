@@ -0,0 +1,91 @@
+// Renderers that draw puzzles and pieces in different ways.
+package gknot
+
+import (
+	"fmt"
+	"io"
+)
+
+// Draws puzzles and pieces to a writer, as text for a terminal or as a
+// picture.
+type Renderer interface {
+	RenderPuzzle(w io.Writer, puzzle *Puzzle) error
+	// Draws the piece as defined, before it is placed in a puzzle.
+	RenderPiece(w io.Writer, piece PieceDefinition) error
+}
+
+// Describes the names RendererByName takes, for a command's -renderer flag.
+const RendererUsage = "How to draw: ansi (coloured blocks for a terminal), text (a letter per piece), svg, png, " +
+	"or auto (ansi if standard output is a terminal and NO_COLOR is not set, else text)."
+
+// Returns the renderer named "ansi", "text", "svg" or "png", or for "auto" the
+// one DefaultRenderer returns. Text is drawn with the glyphs, written as for
+// ParseGlyphs, and PNG pictures with the image options.
+func RendererByName(name, glyphs string, opts ImageOptions) (Renderer, error) {
+	switch name {
+	case "auto":
+		if _, ok := DefaultRenderer().(ANSIRenderer); ok {
+			return ANSIRenderer{}, nil
+		}
+		fallthrough
+	case "text":
+		glyphs, err := ParseGlyphs(glyphs)
+		if err != nil {
+			return nil, err
+		}
+		return TextRenderer{Glyphs: glyphs}, nil
+	case "ansi":
+		return ANSIRenderer{}, nil
+	case "svg":
+		return SVGRenderer{}, nil
+	case "png":
+		return PNGRenderer{opts}, nil
+	}
+	return nil, fmt.Errorf("Unknown renderer %q.", name)
+}
+
+// The extension of the files the renderer draws pictures for, or "" if it
+// draws text.
+func FileExtension(renderer Renderer) string {
+	switch renderer.(type) {
+	case SVGRenderer:
+		return ".svg"
+	case PNGRenderer:
+		return ".png"
+	}
+	return ""
+}
+
+// Draws SVG pictures as Puzzle.WriteSVG does.
+type SVGRenderer struct{}
+
+func (SVGRenderer) RenderPuzzle(w io.Writer, puzzle *Puzzle) error {
+	return puzzle.WriteSVG(w)
+}
+
+// Draws the piece as a puzzle of just that piece.
+func (SVGRenderer) RenderPiece(w io.Writer, piece PieceDefinition) error {
+	puzzle, err := NewPuzzleFrom(piece)
+	if err != nil {
+		return err
+	}
+	return puzzle.WriteSVG(w)
+}
+
+// Draws isometric PNG pictures as Puzzle.WritePNG does.
+type PNGRenderer struct {
+	ImageOptions
+}
+
+func (renderer PNGRenderer) RenderPuzzle(w io.Writer, puzzle *Puzzle) error {
+	return puzzle.WritePNG(w, renderer.ImageOptions)
+}
+
+// Draws the piece as a puzzle of just that piece.
+func (renderer PNGRenderer) RenderPiece(w io.Writer, piece PieceDefinition) error {
+	puzzle, err := NewPuzzleFrom(piece)
+	if err != nil {
+		return err
+	}
+	return puzzle.WritePNG(w, renderer.ImageOptions)
+}
@@ -0,0 +1,145 @@
+package gknot
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// A writer that always fails.
+type failingWriter struct{}
+
+var errWrite = errors.New("Write failed.")
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errWrite
+}
+
+func TestANSIRenderer(t *testing.T) {
+	var b bytes.Buffer
+	if err := (ANSIRenderer{}).RenderPiece(&b, OrangePieceDef); err != nil {
+		t.Fatalf("Expected no error, actual %v.", err)
+	}
+	lines := strings.Split(b.String(), "\n")
+	if expected := "\x1b[1;35mOrange\x1b[0m piece:"; lines[0] != expected {
+		t.Fatalf("Expected first line %q, actual %q.", expected, lines[0])
+	}
+	if expected := "\x1b[0;35m████  ████████"; lines[1] != expected {
+		t.Fatalf("Expected second line %q, actual %q.", expected, lines[1])
+	}
+
+	b.Reset()
+	if err := (ANSIRenderer{Highlight: []uint8{35}}).RenderPuzzle(&b, NewPuzzle()); err != nil {
+		t.Fatalf("Expected no error, actual %v.", err)
+	}
+	if !strings.Contains(b.String(), "\x1b[1;35m▓▓") || strings.Contains(b.String(), "\x1b[1;36m▓▓") {
+		t.Fatalf("Expected only Orange highlighted, actual:\n%v", b.String())
+	}
+	if err := (ANSIRenderer{}).RenderPuzzle(failingWriter{}, NewPuzzle()); err != errWrite {
+		t.Fatalf("Expected %v, actual %v.", errWrite, err)
+	}
+}
+
+func TestSVGRenderer(t *testing.T) {
+	puzzle := NewPuzzle()
+	var expected, actual bytes.Buffer
+	puzzle.WriteSVG(&expected)
+	if err := (SVGRenderer{}).RenderPuzzle(&actual, puzzle); err != nil {
+		t.Fatalf("Expected no error, actual %v.", err)
+	}
+	if actual.String() != expected.String() {
+		t.Fatalf("Expected the same SVG as WriteSVG.")
+	}
+
+	actual.Reset()
+	if err := (SVGRenderer{}).RenderPiece(&actual, RedPieceDef); err != nil {
+		t.Fatalf("Expected no error, actual %v.", err)
+	}
+	if !strings.Contains(actual.String(), hexColor(RedPieceDef.Color())) ||
+		strings.Contains(actual.String(), hexColor(BluePieceDef.Color())) {
+		t.Fatalf("Expected only the Red piece drawn, actual:\n%v", actual.String())
+	}
+}
+
+func TestPNGRenderer(t *testing.T) {
+	var b bytes.Buffer
+	renderer := PNGRenderer{ImageOptions{CellSize: 10}}
+	if err := renderer.RenderPiece(&b, YellowPieceDef); err != nil {
+		t.Fatalf("Expected no error, actual %v.", err)
+	}
+	img, err := png.Decode(&b)
+	if err != nil {
+		t.Fatalf("Expected a PNG image, actual %v.", err)
+	}
+	expected, _ := NewPuzzleFrom(YellowPieceDef)
+	expectedImg, _ := expected.Image(ImageOptions{CellSize: 10})
+	if img.Bounds() != expectedImg.Bounds() {
+		t.Fatalf("Expected bounds %v, actual %v.", expectedImg.Bounds(), img.Bounds())
+	}
+
+	renderer.Camera[X] = 2
+	if err := renderer.RenderPuzzle(&b, NewPuzzle()); err != ErrInvalidImageOptions {
+		t.Fatalf("Expected %v, actual %v.", ErrInvalidImageOptions, err)
+	}
+}
+
+func TestRendererByName(t *testing.T) {
+	opts := ImageOptions{CellSize: 8}
+	for _, test := range []struct {
+		name      string
+		expected  Renderer
+		extension string
+	}{
+		// Tests do not write to a terminal.
+		{"auto", TextRenderer{}, ""},
+		{"text", TextRenderer{}, ""},
+		{"ansi", ANSIRenderer{}, ""},
+		{"svg", SVGRenderer{}, ".svg"},
+		{"png", PNGRenderer{opts}, ".png"},
+	} {
+		renderer, err := RendererByName(test.name, "", opts)
+		if err != nil {
+			t.Fatalf("Expected no error for %v, actual %v.", test.name, err)
+		}
+		if text, ok := renderer.(TextRenderer); ok {
+			if len(text.Glyphs) != 0 {
+				t.Fatalf("Expected no glyphs for %v, actual %v.", test.name, text.Glyphs)
+			}
+			text.Glyphs = nil
+			renderer = text
+		}
+		if !reflect.DeepEqual(renderer, test.expected) {
+			t.Fatalf("Expected %#v for %v, actual %#v.", test.expected, test.name, renderer)
+		}
+		if extension := FileExtension(renderer); extension != test.extension {
+			t.Fatalf("Expected extension %q for %v, actual %q.", test.extension, test.name, extension)
+		}
+	}
+	renderer, err := RendererByName("text", "Blue=#", opts)
+	if err != nil || renderer.(TextRenderer).Glyphs["Blue"] != '#' {
+		t.Fatalf("Expected a TextRenderer drawing Blue as #, actual %#v, error %v.", renderer, err)
+	}
+	if _, err := RendererByName("text", "Blue", opts); err == nil {
+		t.Fatalf("Expected an error for bad glyphs.")
+	}
+	if _, err := RendererByName("html", "", opts); err == nil || err.Error() != `Unknown renderer "html".` {
+		t.Fatalf("Expected an unknown renderer error, actual %v.", err)
+	}
+}
+
+func TestSolution_render(t *testing.T) {
+	puzzle, solution := cupSolution(t)
+	var b bytes.Buffer
+	if err := solution.Render(&b, puzzle, ANSIRenderer{}); err != nil {
+		t.Fatalf("Expected no error, actual %v.", err)
+	}
+	if !strings.Contains(b.String(), "From 5FDC48EE Remove [0 1 0] Pieces [Peg]") {
+		t.Fatalf("Expected the removal of the peg, actual:\n%v", b.String())
+	}
+	if err := solution.Render(failingWriter{}, puzzle, ANSIRenderer{}); err != errWrite {
+		t.Fatalf("Expected %v, actual %v.", errWrite, err)
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"9gel/gknot"
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"image/color"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	puzzleFile = flag.String("puzzle", "", "Puzzle file to replay a solution of. Defaults to the Gordian Knot.")
+	goal       = flag.String("goal", "disassemble",
+		"What to solve for: free (fewest moves to free the first piece) or disassemble (fewest moves "+
+			"in total to take every piece apart).")
+	maxStates = flag.Int("max_states", 0, "Maximum number of states to visit. 0 means no limit.")
+	maxDepth  = flag.Int("max_depth", 0,
+		"Maximum number of moves, not counting removals, to free each group. 0 means no limit.")
+	timeout       = flag.Duration("timeout", 0, "Maximum time to search for. 0 means no limit.")
+	rotations     = flag.Bool("rotations", false, "Also try quarter turns of pieces and groups.")
+	maxSubsetSize = flag.Int("max_subset_size", 0,
+		"Also slide groups of 2 up to this many pieces together as one move. 0 means one piece at a time.")
+	workers = flag.Int("workers", 0, "Number of goroutines to search on. 0 means GOMAXPROCS.")
+	delay   = flag.Duration("delay", time.Second, "Time between steps while playing.")
+	gifFile = flag.String("gif", "",
+		"If set, write an animation of the solution to this GIF file instead of replaying it in the terminal.")
+	cellSize      = flag.Int("cell_size", 24, "Size of the cells in the GIF animation or png pictures, in pixels.")
+	framesPerCell = flag.Int("frames_per_cell", 3, "Frames of the GIF animation for each cell pieces slide.")
+	rendererName  = flag.String("renderer", "auto", gknot.RendererUsage)
+	glyphs        = flag.String("glyphs", "",
+		"Characters to draw pieces with in text, such as Blue=#,Red=%. Defaults to the pieces' initials.")
+	out = flag.String("out", ".", "Directory to write an svg or png file per step to, instead of replaying.")
+)
+
+var goals = map[string]gknot.Goal{
+	"free":        gknot.FreeFirstPiece,
+	"disassemble": gknot.Disassemble,
+}
+
+// What the keys ask the replay to do.
+type command int
+
+const (
+	next     = command(0)
+	previous = command(1)
+	playStop = command(2)
+	quit     = command(3)
+)
+
+const help = "n, space or → next   p or ← previous   enter play/pause   q quit"
+
+func main() {
+	flag.Parse()
+	solveGoal, ok := goals[*goal]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown goal %q.\n", *goal)
+		os.Exit(2)
+	}
+	renderer, err := gknot.RendererByName(*rendererName, *glyphs, gknot.ImageOptions{CellSize: *cellSize})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	puzzle, err := gknot.LoadPuzzleFile(*puzzleFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// Interrupting stops the search, or the replay.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	solution, err := puzzle.SolveContext(ctx, gknot.SolveOptions{
+		Goal:          solveGoal,
+		MaxStates:     *maxStates,
+		MaxDepth:      *maxDepth,
+		Timeout:       *timeout,
+		Rotations:     *rotations,
+		MaxSubsetSize: *maxSubsetSize,
+		Workers:       *workers})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if solution.Reason != gknot.Solved {
+		fmt.Fprintf(os.Stderr, "No solution to replay. Stopped: %v after visiting %v states.\n",
+			solution.Reason, solution.Stats.StatesVisited)
+		os.Exit(1)
+	}
+	if *gifFile != "" {
+		if err := writeGIF(solution, puzzle, *gifFile); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	if extension := gknot.FileExtension(renderer); extension != "" {
+		if err := writeSteps(puzzle, solution.Steps(puzzle), renderer, extension); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+	replay(ctx, renderer, puzzle, solution.Steps(puzzle))
+}
+
+// Returns the renderer drawing the pieces so that they stand out, if it can.
+func highlighted(renderer gknot.Renderer, pieceIDs []uint8) gknot.Renderer {
+	switch r := renderer.(type) {
+	case gknot.ANSIRenderer:
+		r.Highlight = pieceIDs
+		return r
+	case gknot.TextRenderer:
+		r.Highlight = pieceIDs
+		return r
+	}
+	return renderer
+}
+
+// Draws the start and then each step, as the replay shows them, to numbered
+// files in the directory given by the flag, and prints the steps.
+func writeSteps(start *gknot.Puzzle, steps []gknot.Step, renderer gknot.Renderer, extension string) error {
+	for i := 0; i <= len(steps); i++ {
+		puzzle, description := stepState(start, steps, i)
+		f, err := os.Create(filepath.Join(*out, fmt.Sprintf("step%03d%v", i, extension)))
+		if err != nil {
+			return err
+		}
+		if err := renderer.RenderPuzzle(f, puzzle); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		if i > 0 {
+			fmt.Printf("Step %v of %v: %v\n", i, len(steps), description)
+		}
+	}
+	return nil
+}
+
+func writeGIF(solution *gknot.Solution, start *gknot.Puzzle, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	opts := gknot.AnimationOptions{
+		ImageOptions:  gknot.ImageOptions{CellSize: *cellSize, Background: color.White},
+		FramesPerCell: *framesPerCell}
+	if err := solution.WriteGIF(f, start, opts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Shows the start and then each step in place, as the keys direct. If the
+// standard input is not a terminal, plays through every step once.
+func replay(ctx context.Context, renderer gknot.Renderer, start *gknot.Puzzle, steps []gknot.Step) {
+	restore, err := cbreak()
+	if err != nil {
+		for i := 0; i <= len(steps); i++ {
+			draw(renderer, start, steps, i, "")
+			select {
+			case <-time.After(*delay):
+			case <-ctx.Done():
+				return
+			}
+		}
+		return
+	}
+	defer restore()
+
+	commands := make(chan command)
+	go readCommands(commands)
+	i, playing := 0, false
+	for {
+		status := "paused"
+		var tick <-chan time.Time
+		if playing {
+			status = "playing"
+			tick = time.After(*delay)
+		}
+		draw(renderer, start, steps, i, status)
+		select {
+		case cmd := <-commands:
+			switch cmd {
+			case next:
+				if i < len(steps) {
+					i++
+				}
+				playing = false
+			case previous:
+				if i > 0 {
+					i--
+				}
+				playing = false
+			case playStop:
+				if i == len(steps) {
+					i = 0
+				}
+				playing = !playing
+			case quit:
+				return
+			}
+		case <-tick:
+			i++
+			playing = i < len(steps)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// The state after the first i steps and a description of the last of them. A
+// removal gives the state before the pieces are taken off.
+func stepState(start *gknot.Puzzle, steps []gknot.Step, i int) (*gknot.Puzzle, string) {
+	if i == 0 {
+		return start, ""
+	}
+	step := steps[i-1]
+	description := step.From.Describe(step.Move)
+	if step.Move.Removed {
+		return step.From, "Remove " + description
+	}
+	return step.To, description
+}
+
+// Clears the terminal and draws the state after the first i steps, with the
+// pieces moved by the last of them highlighted.
+func draw(renderer gknot.Renderer, start *gknot.Puzzle, steps []gknot.Step, i int, status string) {
+	fmt.Print("\x1b[H\x1b[2J")
+	puzzle, description := stepState(start, steps, i)
+	if i == 0 {
+		renderer.RenderPuzzle(os.Stdout, puzzle)
+		fmt.Printf("Start, %v steps to go.\n", len(steps))
+	} else {
+		highlighted(renderer, steps[i-1].Move.PieceIDs).RenderPuzzle(os.Stdout, puzzle)
+		fmt.Printf("Step %v of %v: %v\n", i, len(steps), description)
+	}
+	if status != "" {
+		fmt.Printf("[%v] %v\n", status, help)
+	}
+}
+
+// Puts the terminal on the standard input in cbreak mode, so that keys are
+// read as they are pressed and not echoed. Returns a function that restores
+// the previous mode.
+func cbreak() (restore func(), err error) {
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return nil, err
+	}
+	return func() { stty(strings.TrimSpace(saved)) }, nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// Reads keys from the standard input and sends the commands they stand for.
+// The arrow keys come as the escape sequences ESC [ C and ESC [ D.
+func readCommands(commands chan<- command) {
+	in := bufio.NewReader(os.Stdin)
+	for {
+		key, err := in.ReadByte()
+		if err != nil {
+			commands <- quit
+			return
+		}
+		if key == '\x1b' {
+			if b, _ := in.ReadByte(); b == '[' {
+				b, _ = in.ReadByte()
+				key = map[byte]byte{'C': 'n', 'D': 'p'}[b]
+			}
+		}
+		switch key {
+		case 'n', ' ':
+			commands <- next
+		case 'p':
+			commands <- previous
+		case '\n', '\r':
+			commands <- playStop
+		case 'q':
+			commands <- quit
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Rotational moves for the solver.
+package gknot
+
+import "math"
+
+// A quarter turn about a line parallel to one of the coordinate axes.
+type Rotation struct {
+	Axis Axis
+	// 1 to turn counter-clockwise when looking from the positive side of the
+	// axis towards the origin, -1 to turn clockwise, 0 for no rotation.
+	Turn int
+	// Twice the coordinates of a point the line goes through, so that it may
+	// pass between cells.
+	Pivot2 Cell
+}
+
+// The axes perpendicular to each axis, ordered such that turning
+// counter-clockwise takes the first one onto the second one.
+var perpendicularAxes = [3][2]Axis{{Y, Z}, {Z, X}, {X, Y}}
+
+// The rigid motion of the rotation. The pivot must be such that cells are
+// turned onto cells.
+func (rotation Rotation) TransformMatrix() TransformMatrix {
+	u, v := perpendicularAxes[rotation.Axis][0], perpendicularAxes[rotation.Axis][1]
+	transform := TransformMatrix{{0, 0, 0, 0}, {0, 0, 0, 0}, {0, 0, 0, 0}, {0, 0, 0, 1}}
+	transform[rotation.Axis][rotation.Axis] = 1
+	// Counter-clockwise, (u, v) goes to (-v, u).
+	transform[u][v] = -rotation.Turn
+	transform[v][u] = rotation.Turn
+	// Keep the pivot in place.
+	for i := 0; i < 3; i++ {
+		rotated := 0
+		for j := 0; j < 3; j++ {
+			rotated += transform[i][j] * rotation.Pivot2[j]
+		}
+		transform[i][3] = (rotation.Pivot2[i] - rotated) / 2
+	}
+	return transform
+}
+
+// Returns the quarter turns of a group of pieces that do not collide with the
+// rest of the puzzle, neither once turned nor while turning. The group is
+// turned about the lines through the centre of its bounding box, moved
+// towards the origin by half a cell if needed to turn cells onto cells.
+func (puzzle *Puzzle) rotations(key StateKey, pieceIDs []uint8) []Move {
+	group := make(map[uint8]bool, len(pieceIDs))
+	var cells Cells
+	for _, pieceID := range pieceIDs {
+		group[pieceID] = true
+		cells = append(cells, puzzle.Pieces[pieceID].Cells...)
+	}
+	var moves []Move
+	for axis := X; axis <= Z; axis++ {
+		var pivot2 Cell
+		for i := X; i <= Z; i++ {
+			min, max := cells.span(i)
+			pivot2[i] = min + max
+		}
+		// Turning cells onto cells needs both perpendicular coordinates of the
+		// pivot to be whole, or both to be halves.
+		u, v := perpendicularAxes[axis][0], perpendicularAxes[axis][1]
+		if (pivot2[u]+pivot2[v])%2 != 0 {
+			pivot2[v]--
+		}
+		for _, turn := range []int{1, -1} {
+			rotation := Rotation{axis, turn, pivot2}
+			if puzzle.canRotate(cells, group, rotation) {
+				moves = append(moves, Move{From: key, PieceIDs: pieceIDs, Rotation: rotation})
+			}
+		}
+	}
+	return moves
+}
+
+// The largest distance, in cells, that a point of a turning cell may move
+// between two checks for collisions.
+const sweepStep = 0.25
+
+// Whether the cells of the group can be rotated without colliding with the
+// rest of the puzzle. The cells swept while turning are found by checking the
+// turning cells for overlaps at small steps of the angle.
+func (puzzle *Puzzle) canRotate(cells Cells, group map[uint8]bool, rotation Rotation) bool {
+	collides := func(cell Cell) bool {
+		existPiece, ok := puzzle.CellMap[cell]
+		return ok && !group[existPiece.Definition.EscColor]
+	}
+	// Quickly rule out turns that end up colliding.
+	transform := rotation.TransformMatrix()
+	for _, cell := range cells {
+		if collides(cell.transform(&transform)) {
+			return false
+		}
+	}
+
+	u, v := perpendicularAxes[rotation.Axis][0], perpendicularAxes[rotation.Axis][1]
+	pivotU, pivotV := float64(rotation.Pivot2[u])/2, float64(rotation.Pivot2[v])/2
+	maxRadius := 0.0
+	for _, cell := range cells {
+		maxRadius = math.Max(maxRadius, math.Hypot(float64(cell[u])-pivotU, float64(cell[v])-pivotV))
+	}
+	// Corners are up to half a diagonal further out than the centres.
+	numSteps := int(math.Ceil((maxRadius + math.Sqrt2/2) * math.Pi / 2 / sweepStep))
+	for step := 1; step < numSteps; step++ {
+		angle := float64(rotation.Turn) * float64(step) / float64(numSteps) * math.Pi / 2
+		sin, cos := math.Sincos(angle)
+		for _, cell := range cells {
+			du, dv := float64(cell[u])-pivotU, float64(cell[v])-pivotV
+			centreU, centreV := pivotU+du*cos-dv*sin, pivotV+du*sin+dv*cos
+			// The turned cell can only overlap cells within half a diagonal.
+			for i := int(math.Floor(centreU - 1)); i <= int(math.Ceil(centreU+1)); i++ {
+				for j := int(math.Floor(centreV - 1)); j <= int(math.Ceil(centreV+1)); j++ {
+					other := cell
+					other[u], other[v] = i, j
+					if collides(other) && squaresOverlap(float64(i)-centreU, float64(j)-centreV, sin, cos) {
+						return false
+					}
+				}
+			}
+		}
+	}
+	return true
+}
+
+// Whether the inside of a unit square centred at the origin, turned by the
+// angle with the given sine and cosine, overlaps the inside of the unit square
+// with sides parallel to the axes centred at (du, dv). Uses the separating
+// axis test: the squares overlap unless they lie on either side of a line
+// perpendicular to one of their sides.
+func squaresOverlap(du, dv, sin, cos float64) bool {
+	const epsilon = 1e-9
+	// Projected onto any of the lines, each square reaches out by half the sum
+	// of its sides' projections.
+	radius := (math.Abs(cos) + math.Abs(sin) + 1) / 2
+	for _, axis := range [][2]float64{{1, 0}, {0, 1}, {cos, sin}, {-sin, cos}} {
+		if math.Abs(axis[0]*du+axis[1]*dv) >= radius-epsilon {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the groups of pieces that can be rotated: every single piece, and
+// every group moved together by one of the moves.
+func (puzzle *Puzzle) rotatableGroups(moves []Move) [][]uint8 {
+	var groups [][]uint8
+	seen := make(map[string]bool)
+	addGroup := func(pieceIDs []uint8) {
+		if seen[string(pieceIDs)] || len(pieceIDs) == len(puzzle.Pieces) {
+			return
+		}
+		seen[string(pieceIDs)] = true
+		groups = append(groups, pieceIDs)
+	}
+	for _, piece := range puzzle.sortedPieces() {
+		addGroup([]uint8{piece.Definition.EscColor})
+	}
+	for _, move := range moves {
+		addGroup(move.PieceIDs)
+	}
+	return groups
+}
@@ -0,0 +1,88 @@
+package gknot
+
+import (
+	"testing"
+)
+
+// A bar of 3 cells along the x axis, and a single cell placed by the transform.
+var (
+	barPieceDef = PieceDefinition{
+		"Bar",
+		31,
+		PieceGeom{{1, 1, 1, 0, 0, 0, 0}},
+		Translation{}.TransformMatrix(),
+		nil}
+	cubePieceDef = PieceDefinition{
+		"Cube",
+		32,
+		PieceGeom{{1, 0, 0, 0, 0, 0, 0}},
+		Translation{}.TransformMatrix(),
+		nil}
+)
+
+// Returns a puzzle with the bar and a cube at the given cell.
+func barAndCube(cubeCell Cell) *Puzzle {
+	cube := cubePieceDef
+	cube.Transform = Translation(cubeCell).TransformMatrix()
+	puzzle := &Puzzle{make(map[uint8]*Piece, 2), make(CellMap)}
+	puzzle.add(barPieceDef.Piece(), cube.Piece())
+	return puzzle
+}
+
+func TestRotation_transformMatrix(t *testing.T) {
+	// Turn counter-clockwise about the z axis through (1, 0).
+	rotation := Rotation{Z, 1, Cell{2, 0, 0}}
+	piece := barPieceDef.Piece()
+	transform := rotation.TransformMatrix()
+	piece.Cells.transform(&transform)
+	checkPiece(t, piece, Cells{{1, -1, 0}, {1, 0, 0}, {1, 1, 0}})
+	// The pivot between cells.
+	rotation = Rotation{Y, -1, Cell{1, 0, 1}}
+	piece = barPieceDef.Piece()
+	transform = rotation.TransformMatrix()
+	piece.Cells.transform(&transform)
+	checkPiece(t, piece, Cells{{1, 0, 0}, {1, 0, 1}, {1, 0, 2}})
+}
+
+func TestRotations(t *testing.T) {
+	// The cube is far enough for the bar to turn every way.
+	puzzle := barAndCube(Cell{5, 5, 5})
+	if moves := puzzle.rotations(puzzle.Key(), []uint8{31}); len(moves) != 6 {
+		t.Fatalf("Bar should turn both ways about each axis, actual %v.", moves)
+	}
+	// The cube is not where the bar ends up after turning counter-clockwise
+	// about the z axis, but in the way while it turns.
+	puzzle = barAndCube(Cell{2, 1, 0})
+	transform := Rotation{Z, 1, Cell{2, 0, 0}}.TransformMatrix()
+	if cell := (Cell{2, 0, 0}).transform(&transform); cell != (Cell{1, 1, 0}) {
+		t.Fatalf("Bar end should turn to (1, 1, 0), actual %v.", cell)
+	}
+	for _, move := range puzzle.rotations(puzzle.Key(), []uint8{31}) {
+		if move.Rotation.Axis == Z && move.Rotation.Turn == 1 {
+			t.Fatalf("Bar should not turn through the cube, actual %v.", move)
+		}
+	}
+	// Clockwise the cube is not in the way.
+	found := false
+	for _, move := range puzzle.rotations(puzzle.Key(), []uint8{31}) {
+		found = found || move.Rotation.Axis == Z && move.Rotation.Turn == -1
+	}
+	if !found {
+		t.Fatalf("Bar should turn clockwise about the z axis.")
+	}
+}
+
+func TestSquaresOverlap(t *testing.T) {
+	if squaresOverlap(1, 0, 0, 1) {
+		t.Fatalf("Squares sharing a side should not overlap.")
+	}
+	if !squaresOverlap(0.9, 0, 0.7071067811865476, 0.7071067811865476) {
+		t.Fatalf("Square turned by 45 degrees should overlap the adjacent square.")
+	}
+	if squaresOverlap(0.9, 0.9, 0.7071067811865476, 0.7071067811865476) {
+		t.Fatalf("Square turned by 45 degrees should not reach the diagonally adjacent square.")
+	}
+	if squaresOverlap(1.5, 0, 0.7071067811865476, 0.7071067811865476) {
+		t.Fatalf("Square turned by 45 degrees should not reach 1.5 cells away.")
+	}
+}
@@ -2,8 +2,128 @@ package main
 
 import (
 	"9gel/gknot"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
 )
 
+var (
+	puzzleFile = flag.String("puzzle", "", "Puzzle file to solve. Defaults to the Gordian Knot.")
+	goal       = flag.String("goal", "disassemble",
+		"What to solve for: explore (visit every reachable state), free (fewest moves to free "+
+			"the first piece) or disassemble (fewest moves in total to take every piece apart).")
+	maxStates = flag.Int("max_states", 0, "Maximum number of states to visit. 0 means no limit.")
+	maxDepth  = flag.Int("max_depth", 0,
+		"Maximum number of moves, not counting removals, to free each group. 0 means no limit.")
+	timeout       = flag.Duration("timeout", 0, "Maximum time to search for. 0 means no limit.")
+	rotations     = flag.Bool("rotations", false, "Also try quarter turns of pieces and groups.")
+	maxSubsetSize = flag.Int("max_subset_size", 0,
+		"Also slide groups of 2 up to this many pieces together as one move. 0 means one piece at a time.")
+	workers      = flag.Int("workers", 0, "Number of goroutines to search on. 0 means GOMAXPROCS.")
+	progress     = flag.Bool("progress", false, "Report the progress of the search on standard error.")
+	rendererName = flag.String("renderer", "auto", gknot.RendererUsage)
+	glyphs       = flag.String("glyphs", "",
+		"Characters to draw pieces with in text, such as Blue=#,Red=%. Defaults to the pieces' initials.")
+	out      = flag.String("out", ".", "Directory to write an svg or png file per state to.")
+	cellSize = flag.Int("cell_size", 24, "Size of the cells in the png pictures, in pixels.")
+)
+
+var goals = map[string]gknot.Goal{
+	"explore":     gknot.Explore,
+	"free":        gknot.FreeFirstPiece,
+	"disassemble": gknot.Disassemble,
+}
+
 func main() {
-	gknot.NewPuzzle().Solve()
+	flag.Parse()
+	solveGoal, ok := goals[*goal]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown goal %q.\n", *goal)
+		os.Exit(2)
+	}
+	renderer, err := gknot.RendererByName(*rendererName, *glyphs, gknot.ImageOptions{CellSize: *cellSize})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	puzzle, err := gknot.LoadPuzzleFile(*puzzleFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	opts := gknot.SolveOptions{
+		Goal:          solveGoal,
+		MaxStates:     *maxStates,
+		MaxDepth:      *maxDepth,
+		Timeout:       *timeout,
+		Rotations:     *rotations,
+		MaxSubsetSize: *maxSubsetSize,
+		Workers:       *workers}
+	if *progress {
+		opts.Progress = func(p gknot.Progress) {
+			fmt.Fprintf(os.Stderr, "Depth %v: %v states visited, %v to expand, %v elapsed.\n",
+				p.Depth, p.StatesVisited, p.FrontierSize, p.Elapsed.Round(time.Millisecond))
+		}
+	}
+	// Interrupting stops the search and prints what it found so far.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	solution, err := puzzle.SolveContext(ctx, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if extension := gknot.FileExtension(renderer); extension == "" {
+		err = solution.Render(os.Stdout, puzzle, renderer)
+	} else {
+		err = writeStates(solution, puzzle, renderer, extension)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if solution.Reason == gknot.Solved && solveGoal != gknot.Explore {
+		fmt.Printf("Level: %v\n", solution.Level())
+	}
+	fmt.Printf("Stopped: %v after visiting %v states.\n", solution.Reason, solution.Stats.StatesVisited)
+}
+
+// Draws the starting state and the state after each step of the solution to
+// numbered files in the directory given by the flag, and prints the steps.
+func writeStates(solution *gknot.Solution, start *gknot.Puzzle, renderer gknot.Renderer, extension string) error {
+	if len(solution.States) == 0 {
+		return nil
+	}
+	if err := writeState(renderer, start, 0, extension); err != nil {
+		return err
+	}
+	steps := solution.Steps(start)
+	for i, step := range steps {
+		if err := writeState(renderer, step.To, i+1, extension); err != nil {
+			return err
+		}
+		description := step.From.Describe(step.Move)
+		if step.Move.Removed {
+			description = "Remove " + description
+		}
+		fmt.Printf("Step %v of %v: %v\n", i+1, len(steps), description)
+	}
+	return nil
+}
+
+// Draws the state to the file for the step.
+func writeState(renderer gknot.Renderer, puzzle *gknot.Puzzle, step int, extension string) error {
+	f, err := os.Create(filepath.Join(*out, fmt.Sprintf("step%03d%v", step, extension)))
+	if err != nil {
+		return err
+	}
+	if err := renderer.RenderPuzzle(f, puzzle); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
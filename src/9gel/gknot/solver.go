@@ -2,7 +2,13 @@
 package gknot
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Translation [3]int
@@ -15,10 +21,394 @@ func (t Translation) TransformMatrix() TransformMatrix {
 		{0, 0, 0, 1}}
 }
 
-// Solve and print each step.
-func (puzzle *Puzzle) Solve() {
-	visitedStates := make(map[StateID]bool)
-	puzzle.nextMoves(visitedStates, "", Translation{0, 0, 0}, nil)
+// The unit translations a group of pieces may be moved by.
+var unitTranslations = []Translation{
+	{1, 0, 0},
+	{-1, 0, 0},
+	{0, 1, 0},
+	{0, -1, 0},
+	{0, 0, 1},
+	{0, 0, -1}}
+
+// What Solve searches for.
+type Goal int
+
+const (
+	// Visit every reachable state depth first, recording each move made. A
+	// group of pieces that becomes free is removed and the remaining pieces
+	// are explored further.
+	Explore = Goal(0)
+	// Find the fewest moves that free a piece, or a group of pieces, from the
+	// rest of the puzzle.
+	FreeFirstPiece = Goal(1)
+	// Find the fewest moves in total, counting removals, that take every piece
+	// apart. A group of pieces that becomes free is removed, and the groups
+	// are then taken apart separately.
+	Disassemble = Goal(2)
+)
+
+// Options controlling Solve. The limits ensure the search stops in time on
+// large puzzles; the search space itself is always finite since free groups
+// are removed.
+type SolveOptions struct {
+	Goal Goal
+	// Maximum number of states to visit. 0 means no limit.
+	MaxStates int
+	// Maximum number of moves, not counting removals, from the start of a
+	// search. Disassemble starts a new search for each group it frees. 0 means
+	// no limit.
+	MaxDepth int
+	// Maximum wall time to search for. 0 means no limit.
+	Timeout time.Duration
+	// Also try quarter turns of single pieces, and of groups of pieces that
+	// move together, about each axis.
+	Rotations bool
+	// Also try sliding every group of 2 up to this many pieces together, each
+	// pushing along the pieces in its way, as a single move. 0 or 1 slides one
+	// piece at a time.
+	MaxSubsetSize int
+	// Number of goroutines to expand the states of FreeFirstPiece and
+	// Disassemble searches on. The solution is the same for any number. 0
+	// means runtime.GOMAXPROCS(0).
+	Workers int
+	// If not nil, called on the solving goroutine to report how far the
+	// search has got: at every depth of FreeFirstPiece and Disassemble
+	// searches, and every progressInterval states of Explore.
+	Progress func(Progress)
+}
+
+// How far a search has got.
+type Progress struct {
+	StatesVisited int
+	// The number of states waiting to be expanded at Depth. 0 for Explore.
+	FrontierSize int
+	// The number of moves, not counting removals, from the start of the
+	// current search.
+	Depth   int
+	Elapsed time.Duration
+}
+
+// The number of states Explore visits between reports of its progress.
+const progressInterval = 1000
+
+// Why Solve stopped searching.
+type StopReason int
+
+const (
+	// The goal was reached. For Explore, every reachable state was visited.
+	Solved = StopReason(0)
+	// Every reachable state was visited without reaching the goal.
+	NoSolution = StopReason(1)
+	// SolveOptions.MaxStates states were visited.
+	MaxStatesReached = StopReason(2)
+	// No solution within SolveOptions.MaxDepth moves.
+	MaxDepthReached = StopReason(3)
+	// SolveOptions.Timeout or the context's deadline passed.
+	TimedOut = StopReason(4)
+	// The context was canceled.
+	Canceled = StopReason(5)
+)
+
+var stopReasonNames = []string{"solved", "no solution", "max states reached", "max depth reached", "timed out",
+	"canceled"}
+
+func (reason StopReason) String() string {
+	if int(reason) < len(stopReasonNames) {
+		return stopReasonNames[reason]
+	}
+	return fmt.Sprintf("StopReason(%d)", int(reason))
+}
+
+// A Move slides a group of pieces one or more cells along an axis, or rotates
+// it, taking the puzzle from one state to another.
+type Move struct {
+	From StateKey
+	To   StateKey
+	// IDs (EscColor) of the moved pieces in ascending order.
+	PieceIDs []uint8
+	// A unit translation repeated Distance times. A removal's translation is
+	// always a unit one.
+	Translation
+	// Turn is 0 unless the move is a rotation, in which case Translation is 0.
+	Rotation Rotation
+	// True if the pieces are free and are removed from the puzzle by sliding
+	// them away along Translation. To is then the state of the remaining pieces.
+	Removed bool
+}
+
+// The number of cells the pieces slide, or 0 for a rotation.
+func (move Move) Distance() int {
+	distance := 0
+	for _, v := range move.Translation {
+		if v < 0 {
+			v = -v
+		}
+		distance += v
+	}
+	return distance
+}
+
+// The transform that moves the pieces.
+func (move Move) TransformMatrix() TransformMatrix {
+	if move.Rotation.Turn != 0 {
+		return move.Rotation.TransformMatrix()
+	}
+	return move.Translation.TransformMatrix()
+}
+
+// The mutations that apply the move to a puzzle in state From.
+func (move Move) Mutations() []Mutation {
+	mutations := make([]Mutation, len(move.PieceIDs))
+	for i, pieceID := range move.PieceIDs {
+		mutations[i] = Mutation{pieceID, move.TransformMatrix()}
+	}
+	return mutations
+}
+
+// Applies the move to a puzzle in state From. For a removal, returns the
+// remaining pieces and the removed ones; otherwise removed is nil.
+func (move Move) Apply(puzzle *Puzzle) (moved, removed *Puzzle) {
+	if move.Removed {
+		return puzzle.Split(move.PieceIDs)
+	}
+	return puzzle.Mutate(move.Mutations()...), nil
+}
+
+// Statistics collected while solving.
+type Stats struct {
+	StatesVisited int
+	// Number of states from which no move leads to an unvisited state.
+	DeadEnds int
+}
+
+// The result of Solve.
+type Solution struct {
+	// For Explore, the moves in the order the solver made them, each leading
+	// to a state that had not been visited before. Otherwise the moves of the
+	// shortest solution found, in order. When disassembling, the moves after a
+	// removal may be made on either the remaining or the removed pieces.
+	Moves []Move
+	// States[0] is the starting state and States[i+1] is the state Moves[i]
+	// leads to. For Explore, these are the states in the order they were visited.
+	States []StateKey
+	// For Explore, the states from which no move leads to an unvisited state
+	// and no group of pieces can be removed.
+	DeadEnds []StateKey
+	Reason   StopReason
+	Stats    Stats
+}
+
+// A move of a solution and the states it goes between.
+type Step struct {
+	Move Move
+	// The puzzle the move is made on and the one it leads to. For a removal,
+	// To holds the remaining pieces and Removed the pieces taken off.
+	From    *Puzzle
+	To      *Puzzle
+	Removed *Puzzle
+}
+
+// Replays the solution's moves from the starting puzzle, one step per move.
+// Moves made on a group after it was taken off are made on the removed pieces.
+func (solution Solution) Steps(start *Puzzle) []Step {
+	if len(solution.States) == 0 {
+		return nil
+	}
+	puzzles := map[StateKey]*Puzzle{solution.States[0]: start}
+	steps := make([]Step, len(solution.Moves))
+	for i, move := range solution.Moves {
+		from := puzzles[move.From]
+		to, removed := move.Apply(from)
+		steps[i] = Step{move, from, to, removed}
+		puzzles[move.To] = to
+		if removed != nil {
+			puzzles[removed.Key()] = removed
+		}
+	}
+	return steps
+}
+
+// The level of a puzzle, as puzzle designers rate its difficulty: the number
+// of moves, counting the removal, to take off the first group of pieces, then
+// the next one and so on. Written like 5.3.2.
+type Level []int
+
+func (level Level) String() string {
+	moves := make([]string, len(level))
+	for i, numMoves := range level {
+		moves[i] = strconv.Itoa(numMoves)
+	}
+	return strings.Join(moves, ".")
+}
+
+// Returns the level of the solution, counting the moves up to each removal.
+// For FreeFirstPiece, this is the first number of the puzzle's level. A
+// Disassemble solution has the fewest moves in total, which may take more
+// than the fewest moves to free the first group, so its first number may be
+// higher than that of FreeFirstPiece.
+func (solution Solution) Level() Level {
+	var level Level
+	numMoves := 0
+	for _, move := range solution.Moves {
+		numMoves++
+		if move.Removed {
+			level = append(level, numMoves)
+			numMoves = 0
+		}
+	}
+	return level
+}
+
+var (
+	ErrNoPieces      = errors.New("Puzzle has no pieces to solve.")
+	ErrNegativeLimit = errors.New("SolveOptions limits must not be negative.")
+	ErrUnknownGoal   = errors.New("SolveOptions.Goal is not a known goal.")
+)
+
+type solver struct {
+	ctx           context.Context
+	opts          SolveOptions
+	start         time.Time
+	visitedStates map[StateKey]bool
+	solution      *Solution
+	// Set once the state or time limit has stopped the search.
+	stopped bool
+	// Set if some moves were not explored because of the depth limit.
+	depthLimited bool
+}
+
+// Returns true, and records why in the solution, if the search has to stop
+// because of the state or time limit.
+func (s *solver) limitReached() bool {
+	if s.stopped {
+		return true
+	}
+	if s.opts.MaxStates > 0 && s.solution.Stats.StatesVisited >= s.opts.MaxStates {
+		s.solution.Reason = MaxStatesReached
+		s.stopped = true
+		return true
+	}
+	return s.interrupted()
+}
+
+// Returns true, and records why in the solution, if the search has to stop
+// because of the context, whose deadline includes the time limit.
+func (s *solver) interrupted() bool {
+	if s.stopped {
+		return true
+	}
+	switch {
+	case s.ctx.Err() == context.DeadlineExceeded:
+		s.solution.Reason = TimedOut
+	case s.ctx.Err() != nil:
+		s.solution.Reason = Canceled
+	default:
+		return false
+	}
+	s.stopped = true
+	return true
+}
+
+// The number of states that may still be visited within the state limit, for
+// expandAll to stop at: 0 if there is no limit, otherwise at least 1.
+func (s *solver) statesLeft() int {
+	if s.opts.MaxStates == 0 {
+		return 0
+	}
+	if left := s.opts.MaxStates - s.solution.Stats.StatesVisited; left > 1 {
+		return left
+	}
+	return 1
+}
+
+// Reports the progress of the search if the options ask for it.
+func (s *solver) report(frontierSize, depth int) {
+	if s.opts.Progress != nil {
+		s.opts.Progress(Progress{s.solution.Stats.StatesVisited, frontierSize, depth, time.Since(s.start)})
+	}
+}
+
+// Whether moves may be made from a state reached by depth moves. Records if
+// the depth limit prevents them.
+func (s *solver) canMove(depth int) bool {
+	if s.opts.MaxDepth > 0 && depth >= s.opts.MaxDepth {
+		s.depthLimited = true
+		return false
+	}
+	return true
+}
+
+// Searches the states reachable from the puzzle as specified by opts and
+// returns the moves made.
+func (puzzle *Puzzle) Solve(opts SolveOptions) (*Solution, error) {
+	return puzzle.SolveContext(context.Background(), opts)
+}
+
+// Like Solve, but stops searching once the context is canceled or its
+// deadline passes, returning the moves made so far.
+func (puzzle *Puzzle) SolveContext(ctx context.Context, opts SolveOptions) (*Solution, error) {
+	s, cancel, err := puzzle.newSolver(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cancel()
+	var solved bool
+	switch opts.Goal {
+	case Explore:
+		puzzle.nextMoves(s, nil, 0)
+		solved = !s.stopped && !s.depthLimited
+	case FreeFirstPiece:
+		s.solution.States = []StateKey{puzzle.Key()}
+		rest, _ := puzzle.freeShortest(s)
+		solved = rest != nil
+	case Disassemble:
+		s.solution.States = []StateKey{puzzle.Key()}
+		solved = puzzle.disassemble(s)
+	default:
+		return nil, ErrUnknownGoal
+	}
+	s.setReason(solved)
+	return s.solution, nil
+}
+
+// Returns a solver for the puzzle, or an error if the options are invalid.
+// The solver's context is done once the time limit has passed too, so that
+// everything that checks the context stops in time. The returned function
+// releases the context and must be called once the search is over.
+func (puzzle *Puzzle) newSolver(ctx context.Context, opts SolveOptions) (*solver, context.CancelFunc, error) {
+	if len(puzzle.Pieces) == 0 {
+		return nil, nil, ErrNoPieces
+	}
+	if opts.MaxStates < 0 || opts.MaxDepth < 0 || opts.Timeout < 0 || opts.MaxSubsetSize < 0 ||
+		opts.Workers < 0 {
+		return nil, nil, ErrNegativeLimit
+	}
+	cancel := context.CancelFunc(func() {})
+	if opts.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+	}
+	s := &solver{
+		ctx:           ctx,
+		opts:          opts,
+		start:         time.Now(),
+		visitedStates: make(map[StateKey]bool),
+		solution:      &Solution{},
+	}
+	return s, cancel, nil
+}
+
+// Records why the search stopped, given whether it reached its goal.
+func (s *solver) setReason(solved bool) {
+	switch {
+	case s.stopped:
+		// The reason has been recorded already.
+	case solved:
+		s.solution.Reason = Solved
+	case s.depthLimited:
+		s.solution.Reason = MaxDepthReached
+	default:
+		s.solution.Reason = NoSolution
+	}
 }
 
 func (puzzle *Puzzle) pushedPieces(cells Cells, xlate Translation, pushedPieces map[string]*Piece) {
@@ -42,47 +432,587 @@ func (puzzle *Puzzle) pushedPieces(cells Cells, xlate Translation, pushedPieces
 	}
 }
 
-func (puzzle *Puzzle) nextMoves(visitedStates map[StateID]bool, lastStateID StateID, lastXlate Translation, lastPieces []string) (seenState bool) {
-	stateID := puzzle.StateID()
-	if _, ok := visitedStates[stateID]; ok {
+// Returns the moves that can be made from the puzzle in state key, including
+// rotations if the options ask for them.
+func (s *solver) moves(puzzle *Puzzle, key StateKey) []Move {
+	moves := puzzle.moves(key)
+	if s.opts.MaxSubsetSize > 1 {
+		moves = append(moves, puzzle.subsetMoves(key, s.opts.MaxSubsetSize, moves)...)
+	}
+	moves = append(moves, puzzle.slides(moves)...)
+	if s.opts.Rotations {
+		for _, group := range puzzle.rotatableGroups(moves) {
+			moves = append(moves, puzzle.rotations(key, group)...)
+		}
+	}
+	return moves
+}
+
+// Returns the translations that can be made from the puzzle in state key. For
+// each piece, see if there is translation in any of the 6 directions, pushing
+// other pieces along if necessary. If all pieces are pushed, it is not a valid
+// movement. Pieces are tried in ascending ID order so that the moves are
+// always listed in the same order.
+func (puzzle *Puzzle) moves(key StateKey) []Move {
+	moves := make([]Move, 0, len(puzzle.Pieces)*len(unitTranslations))
+	for _, piece := range puzzle.sortedPieces() {
+		for _, xlate := range unitTranslations {
+			if move, ok := puzzle.push(key, []*Piece{piece}, xlate); ok {
+				moves = append(moves, move)
+			}
+		}
+	}
+	return moves
+}
+
+// Returns the translation of the pieces and every piece they push along, or
+// false if that would move all of the pieces.
+func (puzzle *Puzzle) push(key StateKey, pieces []*Piece, xlate Translation) (Move, bool) {
+	piecesToMutate := make(map[string]*Piece, len(puzzle.Pieces))
+	var cells Cells
+	for _, piece := range pieces {
+		piecesToMutate[piece.Definition.Name] = piece
+		cells = append(cells, piece.Cells...)
+	}
+	puzzle.pushedPieces(cells, xlate, piecesToMutate)
+	numMutations := len(piecesToMutate)
+	if numMutations == len(puzzle.Pieces) {
+		return Move{}, false
+	}
+	move := Move{From: key, PieceIDs: make([]uint8, 0, numMutations), Translation: xlate}
+	for _, toMutate := range piecesToMutate {
+		move.PieceIDs = append(move.PieceIDs, toMutate.Definition.EscColor)
+	}
+	sort.Sort(pieceIDs(move.PieceIDs))
+	return move, true
+}
+
+// Returns the slides of the groups moved by the unit translations further
+// along, one move for each distance, up to the first piece in their way. A
+// group with nothing in its way is free and is only moved by one cell.
+func (puzzle *Puzzle) slides(moves []Move) []Move {
+	var slides []Move
+	for _, move := range moves {
+		if move.Distance() != 1 {
+			continue
+		}
+		maxDistance := puzzle.slideDistance(move.PieceIDs, move.Translation)
+		for distance := 2; distance <= maxDistance; distance++ {
+			slide := move
+			for i := range slide.Translation {
+				slide.Translation[i] *= distance
+			}
+			slides = append(slides, slide)
+		}
+	}
+	return slides
+}
+
+// Returns the translations of every group of 2 up to maxSize pieces together,
+// pushing along the pieces in their way, that do not move the same pieces the
+// same way as one of the given moves or each other. Groups are tried in
+// ascending ID order.
+func (puzzle *Puzzle) subsetMoves(key StateKey, maxSize int, moves []Move) []Move {
+	seen := make(map[string]bool, len(moves))
+	seenKey := func(move Move) string {
+		return fmt.Sprint(move.Translation, move.PieceIDs)
+	}
+	for _, move := range moves {
+		seen[seenKey(move)] = true
+	}
+	pieces := puzzle.sortedPieces()
+	var subsetMoves []Move
+	subset := make([]*Piece, 0, maxSize)
+	var addSubsets func(start int)
+	addSubsets = func(start int) {
+		if len(subset) > 1 {
+			for _, xlate := range unitTranslations {
+				if move, ok := puzzle.push(key, subset, xlate); ok && !seen[seenKey(move)] {
+					seen[seenKey(move)] = true
+					subsetMoves = append(subsetMoves, move)
+				}
+			}
+		}
+		if len(subset) == maxSize {
+			return
+		}
+		for i := start; i < len(pieces); i++ {
+			subset = append(subset, pieces[i])
+			addSubsets(i + 1)
+			subset = subset[:len(subset)-1]
+		}
+	}
+	addSubsets(0)
+	return subsetMoves
+}
+
+// Visits the puzzle's state, reached by lastMove unless it is the starting
+// state, then recursively the states reachable from it. If a group of pieces
+// is free, it is removed and only the remaining pieces are explored further.
+// depth is the number of moves, not counting removals, that reached the state.
+// Returns false if the state has been visited already.
+func (puzzle *Puzzle) nextMoves(s *solver, lastMove *Move, depth int) (newState bool) {
+	key := puzzle.Key()
+	if _, ok := s.visitedStates[key]; ok {
 		// Have seen this state already.
 		return false
 	}
-	visitedStates[stateID] = true
-	if lastStateID != "" {
-		fmt.Println("From", lastStateID, "Mutate", lastXlate, "Pieces", lastPieces)
-	}
-	puzzle.Print()
-	// For each piece, see if there is translation in any of the 6 directions, pushing other pieces along
-	// if necessary. If all pieces are pushed, it is not a valid movement.
-	// TODO: look for rotation opportunities.
-	translations := []Translation{
-		{1, 0, 0},
-		{-1, 0, 0},
-		{0, 1, 0},
-		{0, -1, 0},
-		{0, 0, 1},
-		{0, 0, -1}}
+	s.visitedStates[key] = true
+	if lastMove != nil {
+		lastMove.To = key
+		s.solution.Moves = append(s.solution.Moves, *lastMove)
+	}
+	s.solution.States = append(s.solution.States, key)
+	s.solution.Stats.StatesVisited++
+	if s.solution.Stats.StatesVisited%progressInterval == 0 {
+		s.report(0, depth)
+	}
+	if len(puzzle.Pieces) < 2 {
+		// Nothing left to take apart.
+		return true
+	}
+	moves := s.moves(puzzle, key)
+	if removal, ok := puzzle.freeGroup(moves); ok {
+		// Remove the free group and carry on with the remaining pieces.
+		if !s.limitReached() {
+			rest, _ := removal.Apply(puzzle)
+			rest.nextMoves(s, &removal, depth)
+		}
+		return true
+	}
+	if !s.canMove(depth) {
+		return true
+	}
 	hasMoreMoves := false
-	for _, piece := range puzzle.Pieces {
-		for _, xlate := range translations {
-			piecesToMutate := map[string]*Piece{piece.Definition.Name: piece}
-			puzzle.pushedPieces(piece.Cells, xlate, piecesToMutate)
-			if numMutations := len(piecesToMutate); numMutations < len(puzzle.Pieces) {
-				mutations := make([]Mutation, 0, numMutations)
-				mutatedPieceNames := make([]string, 0, len(piecesToMutate))
-				for _, toMutate := range piecesToMutate {
-					mutations = append(mutations, Mutation{toMutate.Definition.EscColor, xlate.TransformMatrix()})
-					mutatedPieceNames = append(mutatedPieceNames, toMutate.Definition.Name)
+	for _, move := range moves {
+		if s.limitReached() {
+			return true
+		}
+		if puzzle.Mutate(move.Mutations()...).nextMoves(s, &move, depth+1) {
+			hasMoreMoves = true
+		}
+	}
+	if !hasMoreMoves {
+		s.solution.DeadEnds = append(s.solution.DeadEnds, key)
+		s.solution.Stats.DeadEnds++
+	}
+	return true
+}
+
+// Searches breadth first for the fewest moves that free a group of pieces
+// from the puzzle. If found, appends the moves followed by the removal of the
+// free group to the solution and returns the remaining pieces and the free
+// group.
+func (puzzle *Puzzle) freeShortest(s *solver) (rest, free *Puzzle) {
+	// The move leading to each visited state, nil for the starting state.
+	parents := map[StateKey]*Move{puzzle.Key(): nil}
+	visited := func(key StateKey) bool {
+		_, ok := parents[key]
+		return ok
+	}
+	s.solution.Stats.StatesVisited++
+	var goal *Puzzle
+	var removal Move
+	for frontier, depth := []*Puzzle{puzzle}, 0; goal == nil && len(frontier) > 0; depth++ {
+		var nextFrontier []*Puzzle
+		s.report(len(frontier), depth)
+		for start := 0; goal == nil && start < len(frontier); {
+			expansions := s.expandAll(frontier[start:], depth, expandOptions{
+				visited:      visited,
+				stopAtFree:   true,
+				maxNewStates: s.statesLeft(),
+			})
+			if s.interrupted() {
+				// The expansions may be incomplete.
+				return nil, nil
+			}
+			for i := range expansions {
+				current, e := frontier[start+i], &expansions[i]
+				if e.free {
+					goal, removal = current, e.removal
+					break
 				}
-				if puzzle.Mutate(mutations...).nextMoves(visitedStates, stateID, xlate, mutatedPieceNames) {
+				if !s.canMove(depth) {
+					continue
+				}
+				hasMoreMoves := false
+				for j := range e.moves {
+					move := &e.moves[j]
+					if _, ok := parents[move.To]; ok {
+						continue
+					}
+					if s.limitReached() {
+						return nil, nil
+					}
+					parents[move.To] = move
+					s.solution.Stats.StatesVisited++
+					nextFrontier = append(nextFrontier, e.next[j])
 					hasMoreMoves = true
 				}
+				if !hasMoreMoves {
+					s.solution.Stats.DeadEnds++
+				}
 			}
+			start += len(expansions)
 		}
+		frontier = nextFrontier
 	}
-	if !hasMoreMoves {
-		fmt.Println("No more moves beyond state", stateID)
+	if goal == nil {
+		return nil, nil
+	}
+
+	// Follow the parents back to the starting state.
+	var path []Move
+	for move := parents[removal.From]; move != nil; move = parents[move.From] {
+		path = append(path, *move)
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		s.solution.Moves = append(s.solution.Moves, path[i])
+		s.solution.States = append(s.solution.States, path[i].To)
+	}
+	rest, free = removal.Apply(goal)
+	removal.To = rest.Key()
+	s.solution.Moves = append(s.solution.Moves, removal)
+	s.solution.States = append(s.solution.States, removal.To)
+	return
+}
+
+// How to take apart a group of pieces in some state by the fewest moves in
+// total, counting removals.
+type disassemblyPlan struct {
+	// The number of moves, or -1 if the group cannot be taken apart.
+	cost int
+	// The first of the moves, with To set. A removal if the group is free.
+	move Move
+}
+
+// A state reached while planning how to take apart a group of pieces.
+type planState struct {
+	puzzle *Puzzle
+	plan   disassemblyPlan
+	// Whether the plan was known before the state was reached, in which case
+	// the state is not expanded.
+	planned bool
+	// The states with a move to this one, and those moves.
+	predecessors []int
+	moves        []Move
+}
+
+// Searches for the fewest moves in total, counting removals, that take the
+// puzzle completely apart, and appends them to the solution: the moves up to
+// the first removal, then those that take apart the remaining pieces and
+// then those that take apart the removed ones, each group in turn in the same
+// way. Returns false if the puzzle cannot be taken apart.
+func (puzzle *Puzzle) disassemble(s *solver) bool {
+	plans := make(map[StateKey]disassemblyPlan)
+	if !puzzle.planDisassembly(s, plans) {
+		return false
+	}
+	for groups := []*Puzzle{puzzle}; len(groups) > 0; {
+		group := groups[0]
+		groups = groups[1:]
+		for len(group.Pieces) > 1 {
+			move := plans[group.Key()].move
+			s.solution.Moves = append(s.solution.Moves, move)
+			s.solution.States = append(s.solution.States, move.To)
+			if move.Removed {
+				rest, free := move.Apply(group)
+				groups = append([]*Puzzle{rest}, append(groups, free)...)
+				break
+			}
+			group, _ = move.Apply(group)
+		}
 	}
 	return true
 }
+
+// Plans how to take apart the puzzle, and the groups in every state reachable
+// from it, by the fewest moves in total. A free group is removed straight
+// away, so the fewest moves from a state are either the removal followed by
+// the fewest that take apart both of the groups it leaves, or one more than
+// the fewest from a state a move leads to. The states are explored breadth
+// first up to those with a free group or with a plan already, and the plans
+// are then worked out backwards from them. Returns false if the puzzle
+// cannot be taken apart or the search had to stop.
+func (puzzle *Puzzle) planDisassembly(s *solver, plans map[StateKey]disassemblyPlan) bool {
+	if len(puzzle.Pieces) < 2 {
+		return true
+	}
+	key := puzzle.Key()
+	if plan, ok := plans[key]; ok {
+		return plan.cost >= 0
+	}
+	states := []planState{{puzzle: puzzle, plan: disassemblyPlan{cost: -1}}}
+	indices := map[StateKey]int{key: 0}
+	// States planned already are not visited again either.
+	visited := func(key StateKey) bool {
+		_, reached := indices[key]
+		_, planned := plans[key]
+		return reached || planned
+	}
+	s.solution.Stats.StatesVisited++
+	for frontier, depth := []int{0}, 0; len(frontier) > 0; depth++ {
+		var nextFrontier []int
+		s.report(len(frontier), depth)
+		frontierPuzzles := make([]*Puzzle, len(frontier))
+		for i, current := range frontier {
+			frontierPuzzles[i] = states[current].puzzle
+		}
+		for start := 0; start < len(frontier); {
+			// Every move is needed to work out the plans, so none are left out
+			// for leading to a visited state.
+			expansions := s.expandAll(frontierPuzzles[start:], depth, expandOptions{
+				visited:      visited,
+				keepVisited:  true,
+				maxNewStates: s.statesLeft(),
+			})
+			if s.interrupted() {
+				return false
+			}
+			for i := range expansions {
+				current, e := frontier[start+i], &expansions[i]
+				if e.free {
+					rest, free := e.removal.Apply(states[current].puzzle)
+					if !rest.planDisassembly(s, plans) || !free.planDisassembly(s, plans) {
+						if s.stopped {
+							return false
+						}
+						continue
+					}
+					// A single piece has no plan, and so the zero cost.
+					e.removal.To = rest.Key()
+					states[current].plan = disassemblyPlan{1 + plans[rest.Key()].cost + plans[free.Key()].cost, e.removal}
+					continue
+				}
+				if !s.canMove(depth) {
+					continue
+				}
+				hasMoreMoves := false
+				for j, move := range e.moves {
+					next, ok := indices[move.To]
+					if !ok {
+						hasMoreMoves = true
+						next = len(states)
+						indices[move.To] = next
+						state := planState{puzzle: e.next[j], plan: disassemblyPlan{cost: -1}}
+						if plan, ok := plans[move.To]; ok {
+							state.plan, state.planned = plan, true
+						} else {
+							if s.limitReached() {
+								return false
+							}
+							s.solution.Stats.StatesVisited++
+							nextFrontier = append(nextFrontier, next)
+						}
+						states = append(states, state)
+					}
+					states[next].predecessors = append(states[next].predecessors, current)
+					states[next].moves = append(states[next].moves, move)
+				}
+				if !hasMoreMoves {
+					s.solution.Stats.DeadEnds++
+				}
+			}
+			start += len(expansions)
+		}
+		frontier = nextFrontier
+	}
+
+	// Work out the plans backwards, in ascending order of their number of
+	// moves, starting from the states whose number is known.
+	var byCost [][]int
+	addByCost := func(i int) {
+		for len(byCost) <= states[i].plan.cost {
+			byCost = append(byCost, nil)
+		}
+		byCost[states[i].plan.cost] = append(byCost[states[i].plan.cost], i)
+	}
+	for i := range states {
+		if states[i].plan.cost >= 0 {
+			addByCost(i)
+		}
+	}
+	for cost := 0; cost < len(byCost); cost++ {
+		for _, current := range byCost[cost] {
+			if states[current].plan.cost != cost {
+				continue
+			}
+			for j, previous := range states[current].predecessors {
+				if plan := &states[previous].plan; plan.cost < 0 || plan.cost > cost+1 {
+					*plan = disassemblyPlan{cost + 1, states[current].moves[j]}
+					addByCost(previous)
+				}
+			}
+		}
+	}
+	for _, state := range states {
+		if !state.planned {
+			plans[state.puzzle.Key()] = state.plan
+		}
+	}
+	return states[0].plan.cost >= 0
+}
+
+// Returns the removal of a free group of pieces: one that is apart from the
+// rest of the puzzle, or else the smallest group that can be moved by one of
+// the moves and is free of the rest of the puzzle along that move's direction.
+//
+// Removing free groups is what keeps the number of states finite: pieces
+// that still interlock cannot have a gap between them along any axis, as the
+// pieces on either side of the gap could then be slid apart. So the pieces of
+// a puzzle that has not come apart lie within a box whose sides are no longer
+// than the sum of the pieces' extents along each axis.
+func (puzzle *Puzzle) freeGroup(moves []Move) (removal Move, ok bool) {
+	if removal, ok = puzzle.apartGroup(); ok {
+		return
+	}
+	for _, move := range moves {
+		if move.Distance() != 1 || ok && len(move.PieceIDs) >= len(removal.PieceIDs) {
+			continue
+		}
+		if puzzle.Separable(move.PieceIDs, move.Translation) {
+			removal, ok = move, true
+			removal.Removed = true
+		}
+	}
+	return
+}
+
+// Returns the removal of a group of pieces whose bounding box is apart from
+// that of the rest of the pieces along an axis. Such a group is free without
+// having to check for collisions. The smaller of the two groups is removed.
+func (puzzle *Puzzle) apartGroup() (removal Move, ok bool) {
+	pieces := puzzle.sortedPieces()
+	if len(pieces) < 2 {
+		return
+	}
+	for axis := X; axis <= Z; axis++ {
+		mins := make(map[*Piece]int, len(pieces))
+		maxs := make(map[*Piece]int, len(pieces))
+		for _, piece := range pieces {
+			mins[piece], maxs[piece] = piece.Cells.span(axis)
+		}
+		byMin := make(Pieces, len(pieces))
+		copy(byMin, pieces)
+		sort.Stable(byAxisMin{byMin, mins})
+		lowMax := maxs[byMin[0]]
+		for i := 1; i < len(byMin); i++ {
+			if lowMax < mins[byMin[i]] {
+				group := byMin[i:]
+				removal.Translation[axis] = 1
+				if low := byMin[:i]; len(low) <= len(group) {
+					group = low
+					removal.Translation[axis] = -1
+				}
+				removal.From = puzzle.Key()
+				removal.Removed = true
+				for _, piece := range group {
+					removal.PieceIDs = append(removal.PieceIDs, piece.Definition.EscColor)
+				}
+				sort.Sort(pieceIDs(removal.PieceIDs))
+				return removal, true
+			}
+			if max := maxs[byMin[i]]; max > lowMax {
+				lowMax = max
+			}
+		}
+	}
+	return
+}
+
+type byAxisMin struct {
+	Pieces
+	mins map[*Piece]int
+}
+
+func (p byAxisMin) Less(i, j int) bool { return p.mins[p.Pieces[i]] < p.mins[p.Pieces[j]] }
+
+// Whether the pieces with the given IDs can be translated to infinity by
+// repeating the unit translation xlate, without colliding with the rest of the
+// pieces. False if xlate is not a unit translation, or if the IDs are not of
+// some but not all of the puzzle's pieces.
+func (puzzle *Puzzle) Separable(pieceIDs []uint8, xlate Translation) bool {
+	if !xlate.isUnit() {
+		return false
+	}
+	group := make(map[uint8]bool, len(pieceIDs))
+	for _, pieceID := range pieceIDs {
+		if _, ok := puzzle.Pieces[pieceID]; !ok {
+			return false
+		}
+		group[pieceID] = true
+	}
+	if len(group) == 0 || len(group) == len(puzzle.Pieces) {
+		return false
+	}
+	return puzzle.slideDistance(pieceIDs, xlate) < 0
+}
+
+// Whether the translation is one of the unit translations.
+func (t Translation) isUnit() bool {
+	for _, unit := range unitTranslations {
+		if t == unit {
+			return true
+		}
+	}
+	return false
+}
+
+// The number of times the pieces with the given IDs can be translated by the
+// unit translation xlate before colliding with the rest of the pieces, or -1
+// if they never collide. The pieces must not be all of the puzzle's pieces.
+func (puzzle *Puzzle) slideDistance(pieceIDs []uint8, xlate Translation) int {
+	axis := X
+	for xlate[axis] == 0 {
+		axis++
+	}
+	group := make(map[uint8]bool, len(pieceIDs))
+	var groupCells Cells
+	for _, pieceID := range pieceIDs {
+		group[pieceID] = true
+		groupCells = append(groupCells, puzzle.Pieces[pieceID].Cells...)
+	}
+	var restCells Cells
+	for pieceID, piece := range puzzle.Pieces {
+		if !group[pieceID] {
+			restCells = append(restCells, piece.Cells...)
+		}
+	}
+	// Once the group has moved past all the other cells along the axis, nothing
+	// is in the way any more.
+	groupMin, groupMax := groupCells.span(axis)
+	restMin, restMax := restCells.span(axis)
+	steps := restMax - groupMin + 1
+	if xlate[axis] < 0 {
+		steps = groupMax - restMin + 1
+	}
+	for step := 1; step < steps; step++ {
+		for _, cell := range groupCells {
+			movedCell := Cell{cell[0] + step*xlate[0], cell[1] + step*xlate[1], cell[2] + step*xlate[2]}
+			if existPiece, ok := puzzle.CellMap[movedCell]; ok && !group[existPiece.Definition.EscColor] {
+				return step - 1
+			}
+		}
+	}
+	return -1
+}
+
+// The minimum and maximum coordinates of the cells along the axis.
+func (cells Cells) span(axis Axis) (min, max int) {
+	min, max = cells[0][axis], cells[0][axis]
+	for _, cell := range cells[1:] {
+		if cell[axis] < min {
+			min = cell[axis]
+		}
+		if cell[axis] > max {
+			max = cell[axis]
+		}
+	}
+	return
+}
+
+type pieceIDs []uint8
+
+func (ids pieceIDs) Len() int           { return len(ids) }
+func (ids pieceIDs) Swap(i, j int)      { ids[i], ids[j] = ids[j], ids[i] }
+func (ids pieceIDs) Less(i, j int) bool { return ids[i] < ids[j] }
@@ -0,0 +1,529 @@
+package gknot
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSolve_maxStates(t *testing.T) {
+	solution, err := NewPuzzle().Solve(SolveOptions{MaxStates: 50})
+	if err != nil {
+		t.Fatalf("Solve returned error %v.", err)
+	}
+	if visited := solution.Stats.StatesVisited; visited != 50 {
+		t.Fatalf("Solve should visit 50 states, actual %v.", visited)
+	}
+	if solution.Reason != MaxStatesReached {
+		t.Fatalf("Solve should stop because of MaxStates, actual %v.", solution.Reason)
+	}
+	if numStates, numMoves := len(solution.States), len(solution.Moves); numMoves != numStates-1 {
+		t.Fatalf("Solution with %v states should have %v moves, actual %v.", numStates, numStates-1, numMoves)
+	}
+	if startKey := NewPuzzle().Key(); solution.States[0] != startKey {
+		t.Fatalf("Solution should start from state %q, actual %q.", startKey, solution.States[0])
+	}
+}
+
+// The breadth first searches stop within a depth once they reach the limit.
+func TestSolve_maxStatesBreadthFirst(t *testing.T) {
+	for _, goal := range []Goal{FreeFirstPiece, Disassemble} {
+		for _, workers := range []int{1, 4} {
+			solution, err := NewPuzzle().Solve(SolveOptions{Goal: goal, MaxStates: 50, Workers: workers})
+			if err != nil {
+				t.Fatalf("Solve returned error %v.", err)
+			}
+			if solution.Reason != MaxStatesReached || solution.Stats.StatesVisited != 50 {
+				t.Fatalf("Solving for goal %v on %v workers should stop after 50 states, actual %v after %v.",
+					goal, workers, solution.Reason, solution.Stats.StatesVisited)
+			}
+		}
+	}
+}
+
+// Replaying the moves from the starting puzzle should lead to the recorded states.
+func TestSolve_replayMoves(t *testing.T) {
+	start := NewPuzzle()
+	solution, err := start.Solve(SolveOptions{MaxStates: 50})
+	if err != nil {
+		t.Fatalf("Solve returned error %v.", err)
+	}
+	for i, move := range solution.Moves {
+		if len(move.PieceIDs) == 0 || len(move.PieceIDs) >= len(start.Pieces) {
+			t.Fatalf("Move %v should move some but not all pieces, actual %v.", i, move.PieceIDs)
+		}
+	}
+	replaySolution(t, start, solution)
+}
+
+func TestSolve_maxDepth(t *testing.T) {
+	solution, err := NewPuzzle().Solve(SolveOptions{Goal: FreeFirstPiece, MaxDepth: 26})
+	if err != nil {
+		t.Fatalf("Solve returned error %v.", err)
+	}
+	if solution.Reason != MaxDepthReached {
+		t.Fatalf("Solve should stop because of MaxDepth, actual %v.", solution.Reason)
+	}
+	if numMoves := len(solution.Moves); numMoves != 0 {
+		t.Fatalf("Solve should not find any moves within the depth limit, actual %v.", numMoves)
+	}
+	solution, err = NewPuzzle().Solve(SolveOptions{Goal: FreeFirstPiece, MaxDepth: 27})
+	if err != nil {
+		t.Fatalf("Solve returned error %v.", err)
+	}
+	if solution.Reason != Solved {
+		t.Fatalf("Solve should free the first piece within 27 moves, actual %v.", solution.Reason)
+	}
+}
+
+func TestSolve_timeout(t *testing.T) {
+	solution, err := NewPuzzle().Solve(SolveOptions{Goal: Disassemble, Timeout: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Solve returned error %v.", err)
+	}
+	if solution.Reason != TimedOut {
+		t.Fatalf("Solve should time out, actual %v.", solution.Reason)
+	}
+}
+
+// The time limit stops the states of a depth from being expanded, not only
+// the search between depths.
+func TestSolve_timeoutWithinDepth(t *testing.T) {
+	for _, workers := range []int{1, 4} {
+		s, cancel, err := NewPuzzle().newSolver(context.Background(),
+			SolveOptions{Timeout: time.Millisecond, Workers: workers})
+		if err != nil {
+			t.Fatalf("newSolver returned error %v.", err)
+		}
+		defer cancel()
+		select {
+		case <-s.ctx.Done():
+		case <-time.After(time.Second):
+			t.Fatalf("The solver's context should be done once the time limit has passed.")
+		}
+		frontier := []*Puzzle{NewPuzzle(), NewPuzzle(), NewPuzzle(), NewPuzzle()}
+		notVisited := func(StateKey) bool { return false }
+		if expansions := s.expandAll(frontier, 0, expandOptions{visited: notVisited}); len(expansions) != 0 {
+			t.Fatalf("With %v workers, no state should be expanded after the time limit, actual %v.",
+				workers, len(expansions))
+		}
+		if !s.interrupted() || s.solution.Reason != TimedOut {
+			t.Fatalf("With %v workers, the search should time out, actual %v.", workers, s.solution.Reason)
+		}
+	}
+}
+
+func TestSolveContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, goal := range []Goal{Explore, FreeFirstPiece, Disassemble} {
+		solution, err := NewPuzzle().SolveContext(ctx, SolveOptions{Goal: goal})
+		if err != nil {
+			t.Fatalf("SolveContext returned error %v.", err)
+		}
+		if solution.Reason != Canceled {
+			t.Fatalf("Solving for goal %v should be canceled, actual %v.", goal, solution.Reason)
+		}
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	solution, err := NewPuzzle().SolveContext(ctx, SolveOptions{Goal: Disassemble})
+	if err != nil {
+		t.Fatalf("SolveContext returned error %v.", err)
+	}
+	if solution.Reason != TimedOut {
+		t.Fatalf("Solve should time out, actual %v.", solution.Reason)
+	}
+}
+
+func TestSolve_progress(t *testing.T) {
+	var reports []Progress
+	opts := SolveOptions{Goal: FreeFirstPiece, MaxDepth: 5, Progress: func(progress Progress) {
+		reports = append(reports, progress)
+	}}
+	solution, err := NewPuzzle().Solve(opts)
+	if err != nil {
+		t.Fatalf("Solve returned error %v.", err)
+	}
+	// One report at each depth up to the limit.
+	if len(reports) != 6 {
+		t.Fatalf("Expected 6 progress reports, actual %v.", reports)
+	}
+	if reports[0].StatesVisited != 1 || reports[0].FrontierSize != 1 {
+		t.Fatalf("First report should be of the starting state only, actual %v.", reports[0])
+	}
+	for i, progress := range reports[1:] {
+		previous := reports[i]
+		if progress.Depth != i+1 || progress.StatesVisited != previous.StatesVisited+progress.FrontierSize ||
+			progress.Elapsed < previous.Elapsed {
+			t.Fatalf("Report %v should follow %v, actual %v.", i+1, previous, progress)
+		}
+	}
+	if last := reports[len(reports)-1]; last.StatesVisited != solution.Stats.StatesVisited {
+		t.Fatalf("Last report should have all %v states visited, actual %v.", solution.Stats.StatesVisited, last)
+	}
+}
+
+func TestSolve_cancelFromProgress(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts := SolveOptions{Goal: FreeFirstPiece, Progress: func(progress Progress) {
+		if progress.Depth == 3 {
+			cancel()
+		}
+	}}
+	solution, err := NewPuzzle().SolveContext(ctx, opts)
+	if err != nil {
+		t.Fatalf("SolveContext returned error %v.", err)
+	}
+	if solution.Reason != Canceled || len(solution.Moves) != 0 {
+		t.Fatalf("Solve should be canceled without moves, actual %v with %v moves.",
+			solution.Reason, len(solution.Moves))
+	}
+}
+
+func TestSolve_errors(t *testing.T) {
+	if _, err := NewPuzzle().Solve(SolveOptions{MaxStates: -1}); err != ErrNegativeLimit {
+		t.Fatalf("Expected ErrNegativeLimit, actual %v.", err)
+	}
+	if _, err := NewPuzzle().Solve(SolveOptions{Timeout: -time.Second}); err != ErrNegativeLimit {
+		t.Fatalf("Expected ErrNegativeLimit, actual %v.", err)
+	}
+	if _, err := NewPuzzle().Solve(SolveOptions{Goal: Goal(-1)}); err != ErrUnknownGoal {
+		t.Fatalf("Expected ErrUnknownGoal, actual %v.", err)
+	}
+	if _, err := NewPuzzle().Solve(SolveOptions{MaxSubsetSize: -1}); err != ErrNegativeLimit {
+		t.Fatalf("Expected ErrNegativeLimit, actual %v.", err)
+	}
+	if _, err := NewPuzzle().Solve(SolveOptions{Workers: -1}); err != ErrNegativeLimit {
+		t.Fatalf("Expected ErrNegativeLimit, actual %v.", err)
+	}
+	emptyPuzzle := &Puzzle{make(map[uint8]*Piece), make(CellMap)}
+	if _, err := emptyPuzzle.Solve(SolveOptions{}); err != ErrNoPieces {
+		t.Fatalf("Expected ErrNoPieces, actual %v.", err)
+	}
+}
+
+// Replays the solution's moves from the starting puzzle, checking that each
+// move leads to the recorded state. Returns the puzzles of all states reached.
+func replaySolution(t *testing.T, start *Puzzle, solution *Solution) map[StateKey]*Puzzle {
+	puzzles := map[StateKey]*Puzzle{solution.States[0]: start}
+	for i, move := range solution.Moves {
+		from, ok := puzzles[move.From]
+		if !ok {
+			t.Fatalf("Move %v is made from unreached state %q.", i, move.From)
+		}
+		puzzle, removed := move.Apply(from)
+		if key := puzzle.Key(); key != move.To || key != solution.States[i+1] {
+			t.Fatalf("Move %v should lead to state %q, actual %q.", i, solution.States[i+1], key)
+		}
+		puzzles[move.To] = puzzle
+		if removed != nil {
+			puzzles[removed.Key()] = removed
+		}
+	}
+	return puzzles
+}
+
+func TestSolve_freeFirstPiece(t *testing.T) {
+	start := NewPuzzle()
+	solution, err := start.Solve(SolveOptions{Goal: FreeFirstPiece})
+	if err != nil {
+		t.Fatalf("Solve returned error %v.", err)
+	}
+	if solution.Reason != Solved {
+		t.Fatalf("Solve should free the first piece, actual %v.", solution.Reason)
+	}
+	// 27 moves followed by the removal.
+	if numMoves := len(solution.Moves); numMoves != 28 {
+		t.Fatalf("Shortest solution should have 28 moves, actual %v.", numMoves)
+	}
+	for i, move := range solution.Moves {
+		if move.Removed != (i == len(solution.Moves)-1) {
+			t.Fatalf("Only the last move should be a removal, move %v is %v.", i, move)
+		}
+	}
+	replaySolution(t, start, solution)
+}
+
+func TestSolve_voxelPieces(t *testing.T) {
+	start, err := LoadPuzzleFile("testdata/cup.json")
+	if err != nil {
+		t.Fatalf("LoadPuzzleFile returned error %v.", err)
+	}
+	solution, err := start.Solve(SolveOptions{Goal: FreeFirstPiece})
+	if err != nil {
+		t.Fatalf("Solve returned error %v.", err)
+	}
+	if solution.Reason != Solved {
+		t.Fatalf("Solve should free the peg from the cup, actual %v.", solution.Reason)
+	}
+	last := solution.Moves[len(solution.Moves)-1]
+	if !last.Removed || len(last.PieceIDs) != 1 || last.PieceIDs[0] != 31 {
+		t.Fatalf("Last move should remove the peg, actual %v.", last)
+	}
+	replaySolution(t, start, solution)
+}
+
+func TestSolve_disassemble(t *testing.T) {
+	start := NewPuzzle()
+	solution, err := start.Solve(SolveOptions{Goal: Disassemble})
+	if err != nil {
+		t.Fatalf("Solve returned error %v.", err)
+	}
+	if solution.Reason != Solved {
+		t.Fatalf("Solve should disassemble the puzzle, actual %v.", solution.Reason)
+	}
+	replaySolution(t, start, solution)
+	// Each removal splits a group in two, so it takes one fewer than the
+	// number of pieces to free them all.
+	numRemovals := 0
+	for _, move := range solution.Moves {
+		if move.Removed {
+			numRemovals++
+		}
+	}
+	if expected := len(start.Pieces) - 1; numRemovals != expected {
+		t.Fatalf("Disassembly should have %v removals, actual %v.", expected, numRemovals)
+	}
+	// Fewer than the 69 moves, level 28.21.9.8.3, of freeing each group by the
+	// fewest moves in turn, which is not the fewest in total.
+	if numMoves := len(solution.Moves); numMoves != 65 {
+		t.Fatalf("Fewest moves to take the Gordian Knot apart should be 65, actual %v.", numMoves)
+	}
+	if level := solution.Level().String(); level != "28.21.11.2.3" {
+		t.Fatalf("Gordian Knot should be level 28.21.11.2.3, actual %v.", level)
+	}
+}
+
+func TestLevel(t *testing.T) {
+	solution := Solution{Moves: []Move{{}, {}, {Removed: true}, {Removed: true}, {}, {Removed: true}, {}}}
+	if level := solution.Level(); level.String() != "3.1.2" {
+		t.Fatalf("Expected level 3.1.2, actual %v.", level)
+	}
+	if level := (Solution{}).Level(); level.String() != "" {
+		t.Fatalf("Expected empty level for no moves, actual %v.", level)
+	}
+}
+
+func TestSeparable(t *testing.T) {
+	puzzle := NewPuzzle()
+	for _, move := range puzzle.moves(puzzle.Key()) {
+		if puzzle.Separable(move.PieceIDs, move.Translation) {
+			t.Fatalf("No pieces should be separable from the assembled puzzle, actual %v.", move)
+		}
+	}
+	// Only the Blue and the Orange piece above it.
+	_, puzzle = puzzle.Split([]uint8{35, 36})
+	if !puzzle.Separable([]uint8{35}, Translation{0, 1, 0}) {
+		t.Fatalf("Orange piece should be separable upwards.")
+	}
+	if !puzzle.Separable([]uint8{35}, Translation{1, 0, 0}) {
+		t.Fatalf("Orange piece should be separable along x since it does not overlap Blue along y.")
+	}
+	if puzzle.Separable([]uint8{35}, Translation{0, -1, 0}) {
+		t.Fatalf("Orange piece should not be separable downwards through the Blue piece.")
+	}
+}
+
+func TestSeparable_invalidArguments(t *testing.T) {
+	_, puzzle := NewPuzzle().Split([]uint8{35, 36})
+	for _, test := range []struct {
+		pieceIDs []uint8
+		xlate    Translation
+	}{
+		{[]uint8{35}, Translation{0, 0, 0}},
+		{[]uint8{35}, Translation{0, 2, 0}},
+		{[]uint8{35}, Translation{1, 1, 0}},
+		{nil, Translation{0, 1, 0}},
+		{[]uint8{35, 36}, Translation{0, 1, 0}},
+		{[]uint8{35, 35, 36}, Translation{0, 1, 0}},
+		{[]uint8{31}, Translation{0, 1, 0}},
+		{[]uint8{35, 31}, Translation{0, 1, 0}},
+	} {
+		if puzzle.Separable(test.pieceIDs, test.xlate) {
+			t.Errorf("Pieces %v should not be separable by %v.", test.pieceIDs, test.xlate)
+		}
+	}
+}
+
+// Returns a puzzle of single cubes at the cells, with IDs from 31 upwards.
+func cubes(t *testing.T, cells ...Cell) *Puzzle {
+	defns := make([]PieceDefinition, len(cells))
+	for i, cell := range cells {
+		defns[i] = cubePieceDef
+		defns[i].Name = fmt.Sprintf("Cube %v", i)
+		defns[i].EscColor = uint8(31 + i)
+		defns[i].Transform = Translation(cell).TransformMatrix()
+	}
+	puzzle, err := NewPuzzleFrom(defns...)
+	if err != nil {
+		t.Fatalf("NewPuzzleFrom returned error %v.", err)
+	}
+	return puzzle
+}
+
+func TestSubsetMoves(t *testing.T) {
+	// Cubes 31 and 32 touch along x, so moving either of them along x pushes
+	// the other one, unless it moves away from it.
+	puzzle := cubes(t, Cell{0, 0, 0}, Cell{1, 0, 0}, Cell{5, 0, 0})
+	key := puzzle.Key()
+	moves := puzzle.moves(key)
+	if len(moves) != 18 {
+		t.Fatalf("Expected 18 single piece moves, actual %v.", len(moves))
+	}
+	subsetMoves := puzzle.subsetMoves(key, 2, moves)
+	// 31 and 32 along y or z; 31 and 33 except along x; 32 and 33 except
+	// along -x, which pushes all of the pieces.
+	if len(subsetMoves) != 4+5+5 {
+		t.Fatalf("Expected 14 moves of 2 pieces, actual %v: %v.", len(subsetMoves), subsetMoves)
+	}
+	for _, move := range subsetMoves {
+		if len(move.PieceIDs) != 2 {
+			t.Fatalf("Move should be of 2 pieces, actual %v.", move)
+		}
+		if move.PieceIDs[0] == 31 && move.PieceIDs[1] == 32 && move.Translation[X] != 0 {
+			t.Fatalf("Moving 31 and 32 along x is already a single piece move, actual %v.", move)
+		}
+	}
+	// Moving all three pieces is not a move.
+	if subsetMoves := puzzle.subsetMoves(key, 3, moves); len(subsetMoves) != 14 {
+		t.Fatalf("Expected the same 14 moves with up to 3 pieces, actual %v.", len(subsetMoves))
+	}
+}
+
+func TestSolve_subsetMoves(t *testing.T) {
+	start, err := LoadPuzzleFile("testdata/cup.json")
+	if err != nil {
+		t.Fatalf("LoadPuzzleFile returned error %v.", err)
+	}
+	solution, err := start.Solve(SolveOptions{Goal: Disassemble, MaxSubsetSize: 2})
+	if err != nil {
+		t.Fatalf("Solve returned error %v.", err)
+	}
+	if solution.Reason != Solved {
+		t.Fatalf("Solve should free the peg from the cup, actual %v.", solution.Reason)
+	}
+	replaySolution(t, start, solution)
+}
+
+func TestSlides(t *testing.T) {
+	// Cube 31 can slide 3 cells towards cube 32, which is in the way of
+	// nothing else.
+	puzzle := cubes(t, Cell{0, 0, 0}, Cell{4, 0, 0})
+	slides := puzzle.slides(puzzle.moves(puzzle.Key()))
+	if len(slides) != 4 {
+		t.Fatalf("Expected 4 slides further than a cell, actual %v: %v.", len(slides), slides)
+	}
+	for i, expected := range []Translation{{2, 0, 0}, {3, 0, 0}, {-2, 0, 0}, {-3, 0, 0}} {
+		if slides[i].Translation != expected {
+			t.Fatalf("Slide %v should be %v, actual %v.", i, expected, slides[i])
+		}
+		if distance := slides[i].Distance(); distance != i%2+2 {
+			t.Fatalf("Slide %v should have distance %v, actual %v.", i, i%2+2, distance)
+		}
+	}
+}
+
+func TestSolve_workers(t *testing.T) {
+	start := NewPuzzle()
+	expected, err := start.Solve(SolveOptions{Goal: FreeFirstPiece, Workers: 1})
+	if err != nil {
+		t.Fatalf("Solve returned error %v.", err)
+	}
+	solution, err := start.Solve(SolveOptions{Goal: FreeFirstPiece, Workers: 4})
+	if err != nil {
+		t.Fatalf("Solve returned error %v.", err)
+	}
+	if !reflect.DeepEqual(solution, expected) {
+		t.Fatalf("Solving on 4 workers should give the same solution as on 1, %v states and level %v, "+
+			"actual %v states and level %v.", expected.Stats.StatesVisited, expected.Level(),
+			solution.Stats.StatesVisited, solution.Level())
+	}
+}
+
+func TestExpandAll(t *testing.T) {
+	apart := cubes(t, Cell{0, 0, 0}, Cell{5, 0, 0})
+	notVisited := func(StateKey) bool { return false }
+	for _, workers := range []int{1, 4} {
+		s, cancel, err := NewPuzzle().newSolver(context.Background(), SolveOptions{Workers: workers})
+		if err != nil {
+			t.Fatalf("newSolver returned error %v.", err)
+		}
+		defer cancel()
+		frontier := []*Puzzle{NewPuzzle(), apart, NewPuzzle(), NewPuzzle()}
+		expansions := s.expandAll(frontier, 0, expandOptions{visited: notVisited})
+		if len(expansions) != 4 || !expansions[1].free || len(expansions[0].moves) == 0 {
+			t.Fatalf("With %v workers, every state should be expanded, actual %v.", workers, expansions)
+		}
+		// The states after the free one are not needed.
+		expansions = s.expandAll(frontier, 0, expandOptions{visited: notVisited, stopAtFree: true})
+		if len(expansions) != 2 || !expansions[1].free {
+			t.Fatalf("With %v workers, expansion should stop at the free state, actual %v.", workers, expansions)
+		}
+		// The first state has enough moves to new states.
+		frontier = []*Puzzle{NewPuzzle(), NewPuzzle(), NewPuzzle(), NewPuzzle()}
+		expansions = s.expandAll(frontier, 0, expandOptions{visited: notVisited, maxNewStates: 1})
+		if len(expansions) < 1 || len(expansions) > workers || expansions[0].newStates == 0 {
+			t.Fatalf("With %v workers, expansion should stop after at most %v states, actual %v.",
+				workers, workers, len(expansions))
+		}
+	}
+}
+
+func BenchmarkSolve_freeFirstPiece(b *testing.B) {
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%v", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				NewPuzzle().Solve(SolveOptions{Goal: FreeFirstPiece, Workers: workers})
+			}
+		})
+	}
+}
+
+func BenchmarkSolve_disassemble(b *testing.B) {
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%v", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				NewPuzzle().Solve(SolveOptions{Goal: Disassemble, Workers: workers})
+			}
+		})
+	}
+}
+
+func TestSolution_steps(t *testing.T) {
+	start, err := LoadPuzzleFile("testdata/cup.json")
+	if err != nil {
+		t.Fatalf("LoadPuzzleFile returned error %v.", err)
+	}
+	solution, err := start.Solve(SolveOptions{Goal: FreeFirstPiece})
+	if err != nil {
+		t.Fatalf("Solve returned error %v.", err)
+	}
+	steps := solution.Steps(start)
+	if len(steps) != len(solution.Moves) {
+		t.Fatalf("Expected %v steps, actual %v.", len(solution.Moves), len(steps))
+	}
+	from := start
+	for i, step := range steps {
+		if step.From != from {
+			t.Fatalf("Step %v should be made on the puzzle the step before led to.", i)
+		}
+		if key := step.To.Key(); key != solution.States[i+1] {
+			t.Fatalf("Step %v should lead to state %q, actual %q.", i, solution.States[i+1], key)
+		}
+		if (step.Removed != nil) != step.Move.Removed {
+			t.Fatalf("Only removals should take off pieces, step %v removed %v.", i, step.Removed)
+		}
+		from = step.To
+	}
+	if removed := steps[len(steps)-1].Removed; len(removed.Pieces) != 1 || removed.Pieces[31] == nil {
+		t.Fatalf("Last step should take off the peg, actual %v.", removed.Pieces)
+	}
+	if steps := (Solution{}).Steps(start); steps != nil {
+		t.Fatalf("Expected no steps for an empty solution, actual %v.", steps)
+	}
+}
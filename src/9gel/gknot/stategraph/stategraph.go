@@ -0,0 +1,57 @@
+// Writes the graph of the states reachable from a puzzle, for Graphviz or
+// GraphML tools such as Gephi.
+package main
+
+import (
+	"9gel/gknot"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+var (
+	puzzleFile    = flag.String("puzzle", "", "Puzzle file to explore. Defaults to the Gordian Knot.")
+	format        = flag.String("format", "dot", "Output format: dot or graphml.")
+	maxStates     = flag.Int("max_states", 0, "Maximum number of states in the graph. 0 means no limit.")
+	maxDepth      = flag.Int("max_depth", 0, "Maximum number of moves from the start. 0 means no limit.")
+	rotations     = flag.Bool("rotations", false, "Also try quarter turns of pieces and groups.")
+	maxSubsetSize = flag.Int("max_subset_size", 0,
+		"Also slide groups of 2 up to this many pieces together as one move. 0 means one piece at a time.")
+)
+
+func main() {
+	flag.Parse()
+	if *format != "dot" && *format != "graphml" {
+		fmt.Fprintf(os.Stderr, "Unknown format %q.\n", *format)
+		os.Exit(2)
+	}
+	puzzle, err := gknot.LoadPuzzleFile(*puzzleFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// Interrupting stops exploring and writes the graph so far.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	graph, err := puzzle.StateGraph(ctx, gknot.SolveOptions{
+		MaxStates:     *maxStates,
+		MaxDepth:      *maxDepth,
+		Rotations:     *rotations,
+		MaxSubsetSize: *maxSubsetSize})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *format == "dot" {
+		err = graph.WriteDOT(os.Stdout)
+	} else {
+		err = graph.WriteGraphML(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Stopped: %v with %v states.\n", graph.Reason, len(graph.Nodes))
+}
@@ -0,0 +1,127 @@
+// Drawing puzzles as SVG pictures.
+package gknot
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// The size of a cell in an SVG picture, and the space around and between its
+// panels.
+const (
+	svgCellSize = 20
+	svgMargin   = 40
+	// Below the panels, for the axis keys.
+	svgKeyHeight = 80
+)
+
+// A panel of an SVG picture: a projection of the puzzle along one axis.
+type svgPanel struct {
+	title string
+	axes  [2]Axis
+	// The direction each of the axes points on the page, with y downwards.
+	directions [2][2]int
+}
+
+// The panels are laid out like Puzzle.Print does.
+var svgPanels = []svgPanel{
+	{"x-y", [2]Axis{X, Y}, [2][2]int{{1, 0}, {0, -1}}},
+	{"y-z", [2]Axis{Y, Z}, [2][2]int{{0, -1}, {-1, 0}}},
+	{"x-z", [2]Axis{X, Z}, [2][2]int{{1, 0}, {0, 1}}},
+}
+
+// Writes an SVG picture of the puzzle in the 3 projections of Puzzle.Print,
+// side by side, with each piece in its colour. Cells nearer the viewer are
+// drawn lighter than the ones further back, which are seen through gaps.
+func (puzzle *Puzzle) WriteSVG(w io.Writer) error {
+	var allCells Cells
+	for _, piece := range puzzle.Pieces {
+		allCells = append(allCells, piece.Cells...)
+	}
+	// Lay out the panels first, as the keys go below the tallest one.
+	pageCells := make([]ProjectedCells, len(svgPanels))
+	widths := make([]int, len(svgPanels))
+	height := 0
+	for i, panel := range svgPanels {
+		pageCells[i] = make(ProjectedCells)
+		projected := ProjectPuzzle(panel.axes[0], panel.axes[1], *puzzle)
+		pageCells[i].transformAndAddCells(Transform2D{
+			{panel.directions[0][0], panel.directions[1][0], 0},
+			{panel.directions[0][1], panel.directions[1][1], 0}}, projected)
+		minX, minY := pageCells[i].axesMin()
+		moved := make(ProjectedCells)
+		moved.transformAndAddCells(Transform2D{{1, 0, -minX}, {0, 1, -minY}}, pageCells[i])
+		pageCells[i] = moved
+		maxX, maxY := moved.axesMax()
+		widths[i] = (maxX + 1) * svgCellSize
+		if (maxY+1)*svgCellSize > height {
+			height = (maxY + 1) * svgCellSize
+		}
+	}
+	width := svgMargin
+	for _, panelWidth := range widths {
+		width += panelWidth + svgMargin
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%v\" height=\"%v\" "+
+		"font-family=\"sans-serif\" font-size=\"14\">\n", width, svgMargin+height+svgKeyHeight)
+	b.WriteString("  <defs>\n")
+	b.WriteString("    <marker id=\"arrow\" viewBox=\"0 0 10 10\" refX=\"10\" refY=\"5\" " +
+		"markerWidth=\"6\" markerHeight=\"6\" orient=\"auto\">\n")
+	b.WriteString("      <path d=\"M0,0 L10,5 L0,10 z\"/>\n")
+	b.WriteString("    </marker>\n")
+	b.WriteString("  </defs>\n")
+	left := svgMargin
+	for i, panel := range svgPanels {
+		depthMin, depthMax := allCells.span(3 - panel.axes[0] - panel.axes[1])
+		fmt.Fprintf(&b, "  <g transform=\"translate(%v %v)\">\n", left, svgMargin)
+		fmt.Fprintf(&b, "    <text y=\"-12\" font-weight=\"bold\">%v</text>\n", panel.title)
+		pages := make([]Coords2D, 0, len(pageCells[i]))
+		for page := range pageCells[i] {
+			pages = append(pages, page)
+		}
+		sort.Slice(pages, func(j, k int) bool {
+			return pages[j][1] < pages[k][1] || pages[j][1] == pages[k][1] && pages[j][0] < pages[k][0]
+		})
+		for _, page := range pages {
+			cell := pageCells[i][page]
+			// The furthest cells are drawn at 60% of the colour.
+			factor := 1.0
+			if depthMax > depthMin {
+				factor = 0.6 + 0.4*float64(cell.Depth-depthMin)/float64(depthMax-depthMin)
+			}
+			fmt.Fprintf(&b, "    <rect x=\"%v\" y=\"%v\" width=\"%v\" height=\"%v\" fill=\"%v\" "+
+				"stroke=\"black\" stroke-opacity=\"0.3\"><title>%v</title></rect>\n",
+				page[0]*svgCellSize, page[1]*svgCellSize, svgCellSize, svgCellSize,
+				hexColor(darken(cell.Piece.Definition.Color(), factor)), svgEscape(cell.Piece.Definition.Name))
+		}
+		b.WriteString("  </g>\n")
+		writeSVGKey(&b, panel, left, svgMargin+height+svgKeyHeight/2)
+		left += widths[i] + svgMargin
+	}
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Writes arrows from (x, y), just right of left, showing which way the
+// panel's axes point, each labelled with its axis.
+func writeSVGKey(b *strings.Builder, panel svgPanel, left, y int) {
+	const length = 20
+	x := left + length + 10
+	for i, direction := range panel.directions {
+		x2, y2 := x+direction[0]*length, y+direction[1]*length
+		fmt.Fprintf(b, "  <line x1=\"%v\" y1=\"%v\" x2=\"%v\" y2=\"%v\" stroke=\"black\" "+
+			"marker-end=\"url(#arrow)\"/>\n", x, y, x2, y2)
+		fmt.Fprintf(b, "  <text x=\"%v\" y=\"%v\" text-anchor=\"middle\" dominant-baseline=\"middle\">%v</text>\n",
+			x2+direction[0]*10, y2+direction[1]*10, "xyz"[panel.axes[i]:panel.axes[i]+1])
+	}
+}
+
+// Escapes the text for use in SVG.
+func svgEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(s)
+}
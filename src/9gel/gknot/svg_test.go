@@ -0,0 +1,77 @@
+package gknot
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestWriteSVG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewPuzzle().WriteSVG(&buf); err != nil {
+		t.Fatalf("WriteSVG returned error %v.", err)
+	}
+	var svg struct {
+		Width  int `xml:"width,attr"`
+		Height int `xml:"height,attr"`
+		Panels []struct {
+			Title string `xml:"text"`
+			Rects []struct {
+				Fill  string `xml:"fill,attr"`
+				Title string `xml:"title"`
+			} `xml:"rect"`
+		} `xml:"g"`
+		Labels []string `xml:"text"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &svg); err != nil {
+		t.Fatalf("SVG should be valid XML, actual error %v.", err)
+	}
+	// Each panel is 7 cells wide, with margins of 40 around and between them.
+	if svg.Width != 3*7*svgCellSize+4*svgMargin {
+		t.Fatalf("Expected width %v, actual %v.", 3*7*svgCellSize+4*svgMargin, svg.Width)
+	}
+	if len(svg.Panels) != 3 {
+		t.Fatalf("Expected 3 panels, actual %v.", len(svg.Panels))
+	}
+	for i, title := range []string{"x-y", "y-z", "x-z"} {
+		if svg.Panels[i].Title != title {
+			t.Fatalf("Panel %v should be %v, actual %v.", i, title, svg.Panels[i].Title)
+		}
+	}
+	if labels := strings.Join(svg.Labels, ""); labels != "xyyzxz" {
+		t.Fatalf("Expected axis labels xyyzxz, actual %v.", labels)
+	}
+	// The frontmost cells are in their piece's colour, the ones further back
+	// are shaded darker.
+	rects := svg.Panels[0].Rects
+	if rects[0].Title != "Yellow" {
+		t.Fatalf("Top left cell of x-y panel should be Yellow, actual %v.", rects[0].Title)
+	}
+	numFront := 0
+	for _, rect := range rects {
+		if rect.Fill == hexColor(pieceColors[rect.Title]) {
+			numFront++
+		}
+	}
+	if numFront == 0 || numFront == len(rects) {
+		t.Fatalf("Some but not all cells should be in their piece's colour, actual %v.", rects)
+	}
+}
+
+func TestPieceDefinition_color(t *testing.T) {
+	for _, test := range []struct {
+		defn     PieceDefinition
+		expected color.RGBA
+	}{
+		{OrangePieceDef, pieceColors["Orange"]},
+		{PieceDefinition{Name: "Peg", EscColor: 31}, escColors[1]},
+		{PieceDefinition{Name: "Cap", EscColor: 94}, brightEscColors[4]},
+		{PieceDefinition{Name: "Odd", EscColor: 100}, color.RGBA{0x99, 0x99, 0x99, 0xff}},
+	} {
+		if c := test.defn.Color(); c != test.expected {
+			t.Errorf("%v should be %v, actual %v.", test.defn.Name, test.expected, c)
+		}
+	}
+}
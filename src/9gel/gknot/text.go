@@ -0,0 +1,150 @@
+// Drawing puzzles and pieces as plain text, for logs, files and terminals
+// without colour.
+package gknot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Draws pieces and puzzles as plain text without escape codes, as Print does
+// when standard output is not a terminal. Each cell is drawn as 2 of its
+// piece's character.
+type TextRenderer struct {
+	// The character drawn for each piece, by name. Pieces not in the map are
+	// drawn with the first letter of their name in capitals. In a puzzle, a
+	// piece whose initial another piece already has is drawn with the next
+	// letter no piece has, or else the first such digit.
+	Glyphs map[string]rune
+	// Pieces drawn in lower case so that they stand out, if their characters
+	// have a lower case.
+	Highlight []uint8
+}
+
+// The character drawn for the piece.
+func (renderer TextRenderer) glyph(piece PieceDefinition) rune {
+	if glyph, ok := renderer.Glyphs[piece.Name]; ok {
+		return glyph
+	}
+	initial, _ := utf8.DecodeRuneInString(piece.Name)
+	return unicode.ToUpper(initial)
+}
+
+// The characters drawn for the pieces of a puzzle, by ID, told apart as
+// Glyphs describes. Pieces in Glyphs keep theirs, and the others are given
+// characters in order of ID.
+func (renderer TextRenderer) puzzleGlyphs(puzzle *Puzzle) map[uint8]rune {
+	glyphs := make(map[uint8]rune, len(puzzle.Pieces))
+	taken := make(map[rune]bool, len(puzzle.Pieces))
+	var unnamed []uint8
+	for pieceID, piece := range puzzle.Pieces {
+		if glyph, ok := renderer.Glyphs[piece.Definition.Name]; ok {
+			glyphs[pieceID] = glyph
+			taken[glyph] = true
+		} else {
+			unnamed = append(unnamed, pieceID)
+		}
+	}
+	sort.Slice(unnamed, func(i, j int) bool { return unnamed[i] < unnamed[j] })
+	for _, pieceID := range unnamed {
+		initial := renderer.glyph(*puzzle.Pieces[pieceID].Definition)
+		glyphs[pieceID] = initial
+		for _, glyph := range glyphCandidates(initial) {
+			if !taken[glyph] {
+				glyphs[pieceID] = glyph
+				break
+			}
+		}
+		taken[glyphs[pieceID]] = true
+	}
+	return glyphs
+}
+
+// The characters to try for a piece with the initial, in order: the initial,
+// the capital letters after it and then from A, and the digits.
+func glyphCandidates(initial rune) []rune {
+	candidates := []rune{initial}
+	start := 'A'
+	if initial >= 'A' && initial <= 'Z' {
+		start = initial + 1
+	}
+	for i := rune(0); i < 26; i++ {
+		candidates = append(candidates, 'A'+(start-'A'+i)%26)
+	}
+	for digit := '0'; digit <= '9'; digit++ {
+		candidates = append(candidates, digit)
+	}
+	return candidates
+}
+
+func (renderer TextRenderer) RenderPiece(w io.Writer, piece PieceDefinition) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%v piece:\n", piece.Name)
+	piece.writeCells(bw, strings.Repeat(string(renderer.glyph(piece)), 2))
+	fmt.Fprintln(bw)
+	return bw.Flush()
+}
+
+func (renderer TextRenderer) RenderPuzzle(w io.Writer, puzzle *Puzzle) error {
+	highlight := make(map[uint8]bool, len(renderer.Highlight))
+	for _, pieceID := range renderer.Highlight {
+		highlight[pieceID] = true
+	}
+	glyphs := renderer.puzzleGlyphs(puzzle)
+	screenCells, width := puzzle.screenCells()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "= %v =\n", puzzle.StateID())
+	fmt.Fprintf(bw, "x-y%*cy-z%*cx-z\n", 2*width-3, ' ', 2*width-3, ' ')
+	writeScreenCells(bw, screenCells, func(cell ProjectedCell) string {
+		glyph := glyphs[cell.Piece.Definition.EscColor]
+		if highlight[cell.Piece.Definition.EscColor] {
+			glyph = unicode.ToLower(glyph)
+		}
+		return string([]rune{glyph, glyph})
+	})
+	return bw.Flush()
+}
+
+// Reads glyphs for TextRenderer written as comma separated name=character
+// pairs, such as "Blue=#,Red=%".
+func ParseGlyphs(s string) (map[string]rune, error) {
+	glyphs := make(map[string]rune)
+	if s == "" {
+		return glyphs, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		i := strings.Index(pair, "=")
+		if i < 0 || utf8.RuneCountInString(pair[i+1:]) != 1 {
+			return nil, fmt.Errorf("Glyph %q must be a piece name, = and one character.", pair)
+		}
+		glyph, _ := utf8.DecodeRuneInString(pair[i+1:])
+		glyphs[pair[:i]] = glyph
+	}
+	return glyphs, nil
+}
+
+// The renderer Print draws with: an ANSIRenderer if standard output is a
+// terminal, or else a TextRenderer. A TextRenderer is also used if the
+// NO_COLOR environment variable is set.
+func DefaultRenderer() Renderer {
+	if colorOutput() {
+		return ANSIRenderer{}
+	}
+	return TextRenderer{}
+}
+
+// Whether to print in colour: if standard output is a terminal, or at least a
+// character device, and NO_COLOR is not set.
+func colorOutput() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,154 @@
+package gknot
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files in testdata/text with the actual output.")
+
+// Checks the output against the golden file of the name in testdata/text.
+func checkGolden(t *testing.T, name string, actual []byte) {
+	path := filepath.Join("testdata", "text", name)
+	if *update {
+		if err := os.WriteFile(path, actual, 0644); err != nil {
+			t.Fatalf("Writing %v returned error %v.", path, err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Reading %v returned error %v.", path, err)
+	}
+	if !bytes.Equal(actual, expected) {
+		t.Fatalf("Expected the contents of %v:\n%s\nactual:\n%s", path, expected, actual)
+	}
+}
+
+func TestTextRenderer_renderPuzzle(t *testing.T) {
+	cup, err := LoadPuzzleFile("testdata/cup.json")
+	if err != nil {
+		t.Fatalf("LoadPuzzleFile returned error %v.", err)
+	}
+	orangeTranslated := NewPuzzle().Mutate(Mutation{35, TransformMatrix{
+		{1, 0, 0, 2},
+		{0, 1, 0, 0},
+		{0, 0, 1, 0},
+		{0, 0, 0, 1}}})
+	for _, test := range []struct {
+		golden   string
+		renderer TextRenderer
+		puzzle   *Puzzle
+	}{
+		{"gordianknot.txt", TextRenderer{}, NewPuzzle()},
+		{"orange_translated.txt", TextRenderer{}, orangeTranslated},
+		{"cup_highlighted.txt", TextRenderer{Highlight: []uint8{31}}, cup},
+		{"glyphs.txt", TextRenderer{Glyphs: map[string]rune{"Blue": '#', "Purple": '%', "Green": '.'}}, NewPuzzle()},
+	} {
+		var b bytes.Buffer
+		if err := test.renderer.RenderPuzzle(&b, test.puzzle); err != nil {
+			t.Fatalf("Expected no error for %v, actual %v.", test.golden, err)
+		}
+		checkGolden(t, test.golden, b.Bytes())
+	}
+}
+
+// The Gordian Knot with pieces named as BurrTools names shapes, all with the
+// same initial.
+func sameInitialPuzzle(t *testing.T) *Puzzle {
+	var defns []PieceDefinition
+	for i, defn := range []PieceDefinition{BluePieceDef, OrangePieceDef, PurplePieceDef, GreenPieceDef,
+		RedPieceDef, YellowPieceDef} {
+		defn.Name = fmt.Sprintf("S%v", i+1)
+		defns = append(defns, defn)
+	}
+	puzzle, err := NewPuzzleFrom(defns...)
+	if err != nil {
+		t.Fatalf("NewPuzzleFrom returned error %v.", err)
+	}
+	return puzzle
+}
+
+func TestTextRenderer_sameInitials(t *testing.T) {
+	puzzle := sameInitialPuzzle(t)
+	var b bytes.Buffer
+	if err := (TextRenderer{Highlight: []uint8{32}}).RenderPuzzle(&b, puzzle); err != nil {
+		t.Fatalf("Expected no error, actual %v.", err)
+	}
+	checkGolden(t, "same_initials.txt", b.Bytes())
+
+	// S3 keeps its own character, and the others skip it.
+	glyphs := TextRenderer{Glyphs: map[string]rune{"S3": 'T'}}.puzzleGlyphs(puzzle)
+	expected := map[uint8]rune{31: 'S', 32: 'U', 33: 'V', 34: 'T', 35: 'W', 36: 'X'}
+	for pieceID, glyph := range expected {
+		if glyphs[pieceID] != glyph {
+			t.Fatalf("Expected %q for piece %v, actual %q.", glyph, pieceID, glyphs[pieceID])
+		}
+	}
+}
+
+func TestGlyphCandidates(t *testing.T) {
+	candidates := glyphCandidates('Z')
+	if len(candidates) != 37 || candidates[0] != 'Z' || candidates[1] != 'A' || candidates[26] != 'Z' ||
+		candidates[27] != '0' || candidates[36] != '9' {
+		t.Fatalf("Expected Z, A to Z and 0 to 9, actual %q.", candidates)
+	}
+	if candidates := glyphCandidates('#'); candidates[0] != '#' || candidates[1] != 'A' {
+		t.Fatalf("Expected #, then A, actual %q.", candidates)
+	}
+}
+
+func TestTextRenderer_renderPiece(t *testing.T) {
+	cup, err := LoadPuzzleFile("testdata/cup.json")
+	if err != nil {
+		t.Fatalf("LoadPuzzleFile returned error %v.", err)
+	}
+	var b bytes.Buffer
+	for _, defn := range []PieceDefinition{BluePieceDef, OrangePieceDef, PurplePieceDef, GreenPieceDef,
+		RedPieceDef, YellowPieceDef, *cup.Pieces[33].Definition} {
+		if err := (TextRenderer{}).RenderPiece(&b, defn); err != nil {
+			t.Fatalf("Expected no error for %v, actual %v.", defn.Name, err)
+		}
+	}
+	checkGolden(t, "pieces.txt", b.Bytes())
+
+	if err := (TextRenderer{}).RenderPiece(failingWriter{}, BluePieceDef); err != errWrite {
+		t.Fatalf("Expected %v, actual %v.", errWrite, err)
+	}
+}
+
+func TestParseGlyphs(t *testing.T) {
+	glyphs, err := ParseGlyphs("Blue=#,Red Peg=r,Eq==")
+	if err != nil {
+		t.Fatalf("Expected no error, actual %v.", err)
+	}
+	expected := map[string]rune{"Blue": '#', "Red Peg": 'r', "Eq": '='}
+	if len(glyphs) != len(expected) {
+		t.Fatalf("Expected %v, actual %v.", expected, glyphs)
+	}
+	for name, glyph := range expected {
+		if glyphs[name] != glyph {
+			t.Fatalf("Expected %v, actual %v.", expected, glyphs)
+		}
+	}
+	for _, s := range []string{"Blue", "Blue=", "Blue=ab", "Blue=#,"} {
+		if _, err := ParseGlyphs(s); err == nil {
+			t.Fatalf("Expected an error for %q.", s)
+		}
+	}
+}
+
+func TestDefaultRenderer(t *testing.T) {
+	// Tests do not write to a terminal.
+	if _, ok := DefaultRenderer().(TextRenderer); !ok {
+		t.Fatalf("Expected a TextRenderer when standard output is not a terminal, actual %T.", DefaultRenderer())
+	}
+	t.Setenv("NO_COLOR", "1")
+	if _, ok := DefaultRenderer().(TextRenderer); !ok {
+		t.Fatalf("Expected a TextRenderer when NO_COLOR is set, actual %T.", DefaultRenderer())
+	}
+}